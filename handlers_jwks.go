@@ -0,0 +1,12 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/sheltonFr/bootdev/chirspy/internal/utils"
+)
+
+func (cfg *apiConfig) handlerJWKS(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	utils.RespondWithJSON(w, http.StatusOK, cfg.jwtKeys.JWKS())
+}
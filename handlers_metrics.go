@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+
+	"github.com/sheltonFr/bootdev/chirspy/internal/utils"
 )
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -26,9 +29,43 @@ func (cfg *apiConfig) handleMetrics(w http.ResponseWriter, _ *http.Request) {
 	w.Write([]byte(res))
 }
 
-func (cfg *apiConfig) handlerReset(w http.ResponseWriter, _ *http.Request) {
+// handlerReset wipes every chirp, refresh token, webhook and non-admin user
+// along with the hit counter. Admins are kept so /admin stays reachable after
+// a reset; the first admin has to be promoted in the database directly. It is
+// only available when PLATFORM is "dev".
+func (cfg *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
+	if cfg.platform != platformDev {
+		utils.RespondWithError(w, http.StatusForbidden, "Reset is only allowed in dev environment")
+		return
+	}
+
+	if err := cfg.resetDatabase(r.Context()); err != nil {
+		cfg.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not reset database")
+		return
+	}
+
 	cfg.fileServerHits.Store(0)
 	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Hits reseted to 0"))
+	w.Write([]byte("Hits reseted to 0 and database reset to initial state"))
+}
+
+// resetDatabase deletes the non-admin users and truncates the other tables in
+// one transaction, so a failure leaves the database as it was.
+func (cfg *apiConfig) resetDatabase(ctx context.Context) error {
+	tx, err := cfg.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q := cfg.db.WithTx(tx)
+	if err := q.DeleteNonAdminUsers(ctx); err != nil {
+		return err
+	}
+	if err := q.ResetDatabase(ctx); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
@@ -0,0 +1,114 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+	"github.com/sheltonFr/bootdev/chirspy/internal/retryqueue"
+)
+
+// MaxDeliveryAttempts is how many times an activity is sent before the
+// delivery is given up on.
+const MaxDeliveryAttempts = 10
+
+var deliveryPolicy = retryqueue.Policy{
+	MaxAttempts:     MaxDeliveryAttempts,
+	FirstRetryDelay: 30 * time.Second,
+	MaxRetryDelay:   12 * time.Hour,
+	// A send is bounded by fetchTimeout.
+	Lease: time.Minute,
+}
+
+// RunDeliveryWorker sends queued activities every interval until ctx is
+// cancelled.
+func (f *Federation) RunDeliveryWorker(ctx context.Context, interval time.Duration) {
+	f.deliveries.RunWorker(ctx, interval)
+}
+
+// DeliverDue sends every delivery that is due. Failed sends are rescheduled
+// with an exponential backoff until MaxDeliveryAttempts.
+func (f *Federation) DeliverDue(ctx context.Context) error {
+	return f.deliveries.DeliverDue(ctx)
+}
+
+// deliveryStore is the retryqueue.Store of ap_deliveries.
+type deliveryStore struct {
+	f *Federation
+}
+
+func (s deliveryStore) ClaimNext(ctx context.Context, lease time.Duration) (database.ApDelivery, error) {
+	return s.f.db.ClaimNextDelivery(ctx, lease.Seconds())
+}
+
+func (s deliveryStore) Attempts(delivery database.ApDelivery) int32 {
+	return delivery.Attempts
+}
+
+func (s deliveryStore) MarkDelivered(ctx context.Context, delivery database.ApDelivery, _ int) error {
+	return s.f.db.MarkDeliveryDelivered(ctx, delivery.ID)
+}
+
+func (s deliveryStore) Retry(ctx context.Context, delivery database.ApDelivery, _ int, sendErr error, next time.Time) error {
+	return s.f.db.RetryDelivery(ctx, database.RetryDeliveryParams{
+		ID:            delivery.ID,
+		NextAttemptAt: next,
+		LastError:     sendErr.Error(),
+	})
+}
+
+func (s deliveryStore) Fail(ctx context.Context, delivery database.ApDelivery, _ int, sendErr error) error {
+	s.f.logger.Printf("Giving up delivery %d to %s: %v\n", delivery.ID, delivery.InboxUrl, sendErr)
+	return s.f.db.FailDelivery(ctx, database.FailDeliveryParams{
+		ID:        delivery.ID,
+		LastError: sendErr.Error(),
+	})
+}
+
+// Send posts a queued activity to its inbox, signed with the sender's key.
+// Rejections other than timeouts and rate limiting are permanent.
+func (s deliveryStore) Send(ctx context.Context, delivery database.ApDelivery) (int, error) {
+	f := s.f
+	if err := f.checkRemoteURL(delivery.InboxUrl); err != nil {
+		return 0, retryqueue.Permanent(err)
+	}
+	actorKey, err := f.actorKey(ctx, delivery.SenderID)
+	if err != nil {
+		return 0, err
+	}
+	key, err := ParsePrivateKey(actorKey.PrivateKeyPem)
+	if err != nil {
+		return 0, err
+	}
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.InboxUrl, bytes.NewReader(body))
+	if err != nil {
+		return 0, retryqueue.Permanent(err)
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("Accept", ContentType)
+	if err := SignRequest(req, f.KeyID(delivery.SenderID), key, body); err != nil {
+		return 0, err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxDocumentSize))
+		return resp.StatusCode, nil
+	}
+
+	err = retryqueue.ResponseError(resp)
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return resp.StatusCode, retryqueue.Permanent(err)
+	}
+	return resp.StatusCode, err
+}
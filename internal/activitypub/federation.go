@@ -0,0 +1,387 @@
+package activitypub
+
+import (
+	"context"
+	"crypto/rsa"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+	"github.com/sheltonFr/bootdev/chirspy/internal/retryqueue"
+)
+
+const (
+	maxDocumentSize = 1 << 20
+	fetchTimeout    = 10 * time.Second
+)
+
+// ErrNotFederated is returned for users that can't be represented as actors,
+// which are the ones without a username.
+var ErrNotFederated = errors.New("user has no username")
+
+// Federation publishes local users and their chirps over ActivityPub. Actor
+// and object IDs are URLs under baseURL, so it has to be the public address
+// of the server.
+type Federation struct {
+	db      *database.Queries
+	logger  *log.Logger
+	baseURL string
+	host    string
+	client  *http.Client
+	// allowInsecure lets a server running over plain HTTP, such as a local
+	// development instance, federate with other plain HTTP servers.
+	allowInsecure bool
+	deliveries    *retryqueue.Queue[database.ApDelivery]
+}
+
+func NewFederation(db *database.Queries, logger *log.Logger, baseURL string) (*Federation, error) {
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q", baseURL)
+	}
+	f := &Federation{
+		db:            db,
+		logger:        logger,
+		baseURL:       base.String(),
+		host:          base.Host,
+		client:        newRemoteClient(false),
+		allowInsecure: base.Scheme == "http",
+	}
+	f.deliveries = retryqueue.New("ActivityPub", logger, deliveryStore{f}, deliveryPolicy)
+	return f, nil
+}
+
+// AllowPrivateNetworks lets requests reach loopback and private addresses,
+// for instances federating with each other on a local network. It has to be
+// called before the federation is used.
+func (f *Federation) AllowPrivateNetworks() {
+	f.client = newRemoteClient(true)
+}
+
+// Host is the domain local accounts live on, as used in acct: URIs.
+func (f *Federation) Host() string {
+	return f.host
+}
+
+func (f *Federation) ActorURL(userID uuid.UUID) string {
+	return fmt.Sprintf("%s/ap/users/%s", f.baseURL, userID)
+}
+
+func (f *Federation) KeyID(userID uuid.UUID) string {
+	return f.ActorURL(userID) + "#main-key"
+}
+
+func (f *Federation) NoteURL(chirpID uuid.UUID) string {
+	return fmt.Sprintf("%s/ap/chirps/%s", f.baseURL, chirpID)
+}
+
+// Actor builds the actor document of a local user, creating their signing
+// key the first time.
+func (f *Federation) Actor(ctx context.Context, user database.User) (Actor, error) {
+	if !user.Username.Valid {
+		return Actor{}, ErrNotFederated
+	}
+	key, err := f.actorKey(ctx, user.ID)
+	if err != nil {
+		return Actor{}, err
+	}
+
+	actorURL := f.ActorURL(user.ID)
+	actor := Actor{
+		Context:           ldContext,
+		ID:                actorURL,
+		Type:              "Person",
+		PreferredUsername: user.Username.String,
+		Name:              user.DisplayName,
+		Summary:           htmlParagraph(user.Bio),
+		URL:               fmt.Sprintf("%s/api/users/%s", f.baseURL, user.ID),
+		Inbox:             actorURL + "/inbox",
+		Outbox:            actorURL + "/outbox",
+		Followers:         actorURL + "/followers",
+		PublicKey: PublicKey{
+			ID:           f.KeyID(user.ID),
+			Owner:        actorURL,
+			PublicKeyPem: key.PublicKeyPem,
+		},
+	}
+	if user.AvatarUrl != "" {
+		actor.Icon = &Image{Type: "Image", URL: user.AvatarUrl}
+	}
+	return actor, nil
+}
+
+// Note maps a chirp to the Note published for it. Replies point at the
+// local Note of their parent.
+func (f *Federation) Note(chirp database.Chirp) Note {
+	actorURL := f.ActorURL(chirp.UserID)
+	note := Note{
+		ID:           f.NoteURL(chirp.ID),
+		Type:         "Note",
+		AttributedTo: actorURL,
+		Content:      htmlParagraph(chirp.Body),
+		URL:          fmt.Sprintf("%s/api/chirps/%s", f.baseURL, chirp.ID),
+		Published:    chirp.CreatedAt.UTC(),
+		To:           []string{Public},
+		Cc:           []string{actorURL + "/followers"},
+	}
+	if chirp.InReplyTo.Valid {
+		note.InReplyTo = f.NoteURL(chirp.InReplyTo.UUID)
+	}
+	return note
+}
+
+// Federates reports whether a chirp is published as a Note. Rechirps have no
+// content of their own and are left out.
+func Federates(chirp database.Chirp) bool {
+	return chirp.Kind != "rechirp" && !chirp.DeletedAt.Valid
+}
+
+// CreateActivity wraps the Note of a chirp in the Create activity announcing
+// it.
+func (f *Federation) CreateActivity(chirp database.Chirp) Activity {
+	note := f.Note(chirp)
+	return Activity{
+		Context:   ldContext,
+		ID:        note.ID + "/activity",
+		Type:      "Create",
+		Actor:     note.AttributedTo,
+		Object:    note,
+		Published: note.Published,
+		To:        note.To,
+		Cc:        note.Cc,
+	}
+}
+
+// EnqueueCreate queues the Create activity of a new chirp for each remote
+// follower of its author. q is usually bound to the transaction creating the
+// chirp, so the delivery is queued only if the chirp is.
+func (f *Federation) EnqueueCreate(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if !Federates(chirp) {
+		return nil
+	}
+	return f.enqueueToFollowers(ctx, q, chirp.UserID, f.CreateActivity(chirp))
+}
+
+// EnqueueDelete queues the Delete activity retracting a chirp's Note.
+func (f *Federation) EnqueueDelete(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if chirp.Kind == "rechirp" {
+		return nil
+	}
+	actorURL := f.ActorURL(chirp.UserID)
+	noteURL := f.NoteURL(chirp.ID)
+	return f.enqueueToFollowers(ctx, q, chirp.UserID, Activity{
+		Context: ldContext,
+		ID:      noteURL + "#delete",
+		Type:    "Delete",
+		Actor:   actorURL,
+		Object:  Tombstone{ID: noteURL, Type: "Tombstone"},
+		To:      []string{Public},
+		Cc:      []string{actorURL + "/followers"},
+	})
+}
+
+func (f *Federation) enqueueToFollowers(ctx context.Context, q *database.Queries, senderID uuid.UUID, activity Activity) error {
+	payload, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+	return q.EnqueueDeliveryToFollowers(ctx, database.EnqueueDeliveryToFollowersParams{
+		Payload:  string(payload),
+		SenderID: senderID,
+	})
+}
+
+// AcceptFollow records follower as a follower of the local user and queues
+// the Accept answering their Follow activity.
+func (f *Federation) AcceptFollow(ctx context.Context, userID uuid.UUID, follow IncomingActivity, follower Actor) error {
+	if err := f.checkRemoteURL(follower.Inbox); err != nil {
+		return err
+	}
+	var sharedInbox string
+	if follower.Endpoints != nil && f.checkRemoteURL(follower.Endpoints.SharedInbox) == nil {
+		sharedInbox = follower.Endpoints.SharedInbox
+	}
+
+	err := f.db.UpsertRemoteFollower(ctx, database.UpsertRemoteFollowerParams{
+		UserID:         userID,
+		ActorUri:       follower.ID,
+		InboxUrl:       follower.Inbox,
+		SharedInboxUrl: sharedInbox,
+	})
+	if err != nil {
+		return err
+	}
+
+	actorURL := f.ActorURL(userID)
+	payload, err := json.Marshal(Activity{
+		Context: ldContext,
+		ID:      fmt.Sprintf("%s#accepts/%s", actorURL, uuid.New()),
+		Type:    "Accept",
+		Actor:   actorURL,
+		Object:  follow,
+	})
+	if err != nil {
+		return err
+	}
+	return f.db.EnqueueDelivery(ctx, database.EnqueueDeliveryParams{
+		SenderID: userID,
+		InboxUrl: follower.Inbox,
+		Payload:  string(payload),
+	})
+}
+
+func (f *Federation) RemoveFollower(ctx context.Context, userID uuid.UUID, actorURI string) error {
+	return f.db.DeleteRemoteFollower(ctx, database.DeleteRemoteFollowerParams{
+		UserID:   userID,
+		ActorUri: actorURI,
+	})
+}
+
+// VerifyInboxRequest checks the HTTP signature of a request posted to an
+// inbox and returns the actor who signed it.
+func (f *Federation) VerifyInboxRequest(r *http.Request, body []byte) (Actor, error) {
+	var signer Actor
+	_, err := VerifyRequest(r, body, func(keyID string) (*rsa.PublicKey, error) {
+		actorURI, _, _ := strings.Cut(keyID, "#")
+		actor, err := f.FetchActor(r.Context(), actorURI)
+		if err != nil {
+			return nil, err
+		}
+		if actor.PublicKey.ID != keyID {
+			return nil, errors.New("key does not belong to actor")
+		}
+		signer = actor
+		return ParsePublicKey(actor.PublicKey.PublicKeyPem)
+	})
+	return signer, err
+}
+
+// FetchActor dereferences a remote actor.
+func (f *Federation) FetchActor(ctx context.Context, actorURI string) (Actor, error) {
+	var actor Actor
+	if err := f.fetchJSON(ctx, actorURI, ContentType+", "+LDContentType, &actor); err != nil {
+		return Actor{}, err
+	}
+	if actor.ID != actorURI {
+		return Actor{}, fmt.Errorf("actor %s claims to be %s", actorURI, actor.ID)
+	}
+	if actor.PublicKey.PublicKeyPem == "" {
+		return Actor{}, fmt.Errorf("actor %s has no public key", actorURI)
+	}
+	if err := f.checkRemoteURL(actor.Inbox); err != nil {
+		return Actor{}, fmt.Errorf("actor %s: %w", actorURI, err)
+	}
+	return actor, nil
+}
+
+// fetchJSON gets a document from another server and decodes it into v.
+func (f *Federation) fetchJSON(ctx context.Context, rawURL, accept string, v any) error {
+	if err := f.checkRemoteURL(rawURL); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", accept)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching %s: %s", rawURL, resp.Status)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDocumentSize)).Decode(v); err != nil {
+		return fmt.Errorf("decoding %s: %w", rawURL, err)
+	}
+	return nil
+}
+
+// checkRemoteURL only lets requests go out to absolute HTTPS URLs, or HTTP
+// ones when the server itself runs over HTTP.
+func (f *Federation) checkRemoteURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid remote URL %q", rawURL)
+	}
+	if u.Scheme != "https" && !(f.allowInsecure && u.Scheme == "http") {
+		return fmt.Errorf("remote URL %q must use https", rawURL)
+	}
+	return nil
+}
+
+// newRemoteClient returns the client fetches and deliveries go through.
+// Remote URLs come from other servers, so unless allowPrivate is set, its
+// dialer refuses addresses that aren't public once the host is resolved,
+// whether reached directly or through a redirect. Proxies are not used, as
+// the check would apply to the proxy instead of the remote server.
+func newRemoteClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: fetchTimeout}
+	if !allowPrivate {
+		dialer.Control = refusePrivateAddress
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: fetchTimeout, Transport: transport}
+}
+
+// refusePrivateAddress is a net.Dialer Control hook rejecting loopback,
+// private, link-local and unspecified addresses.
+func refusePrivateAddress(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", address, err)
+	}
+	ip := addrPort.Addr().Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("refusing to connect to non-public address %s", ip)
+	}
+	return nil
+}
+
+// actorKey returns the signing key of a local user, generating it on first
+// use. Concurrent first uses may both generate a key; only one is kept.
+func (f *Federation) actorKey(ctx context.Context, userID uuid.UUID) (database.ActorKey, error) {
+	key, err := f.db.GetActorKey(ctx, userID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return key, err
+	}
+
+	privatePEM, publicPEM, err := GenerateKey()
+	if err != nil {
+		return database.ActorKey{}, err
+	}
+	err = f.db.CreateActorKey(ctx, database.CreateActorKeyParams{
+		UserID:        userID,
+		PrivateKeyPem: privatePEM,
+		PublicKeyPem:  publicPEM,
+	})
+	if err != nil {
+		return database.ActorKey{}, err
+	}
+	return f.db.GetActorKey(ctx, userID)
+}
+
+func htmlParagraph(text string) string {
+	if text == "" {
+		return ""
+	}
+	return "<p>" + strings.ReplaceAll(html.EscapeString(text), "\n", "<br>") + "</p>"
+}
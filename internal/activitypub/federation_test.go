@@ -0,0 +1,122 @@
+package activitypub
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newRemoteActor serves the actor document of alice, signing with the key
+// of publicPEM, as a stand-in remote server on a loopback address.
+func newRemoteActor(t *testing.T, publicPEM string) *httptest.Server {
+	t.Helper()
+	var remote *httptest.Server
+	remote = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/alice" {
+			http.NotFound(w, r)
+			return
+		}
+		actorURL := remote.URL + "/users/alice"
+		w.Header().Set("Content-Type", ContentType)
+		json.NewEncoder(w).Encode(Actor{
+			ID:                actorURL,
+			Type:              "Person",
+			PreferredUsername: "alice",
+			Inbox:             actorURL + "/inbox",
+			PublicKey: PublicKey{
+				ID:           actorURL + "#main-key",
+				Owner:        actorURL,
+				PublicKeyPem: publicPEM,
+			},
+		})
+	}))
+	t.Cleanup(remote.Close)
+	return remote
+}
+
+func newTestFederation(t *testing.T) *Federation {
+	t.Helper()
+	federation, err := NewFederation(nil, log.New(io.Discard, "", 0), "http://chirpy.example")
+	if err != nil {
+		t.Fatalf("Failed to set up federation: %v", err)
+	}
+	return federation
+}
+
+func TestVerifyInboxRequest(t *testing.T) {
+	privatePEM, publicPEM := newTestKey(t)
+	remote := newRemoteActor(t, publicPEM)
+	federation := newTestFederation(t)
+	federation.AllowPrivateNetworks()
+	body := []byte(`{"type":"Follow"}`)
+	const target = "http://chirpy.example/ap/users/bob/inbox"
+
+	t.Run("Key of the actor", func(t *testing.T) {
+		req := signedRequest(t, privatePEM, remote.URL+"/users/alice#main-key", target, body)
+		actor, err := federation.VerifyInboxRequest(req, body)
+		if err != nil {
+			t.Fatalf("Verification failed: %v", err)
+		}
+		if actor.ID != remote.URL+"/users/alice" {
+			t.Fatalf("Expected actor %s, got %s", remote.URL+"/users/alice", actor.ID)
+		}
+	})
+
+	t.Run("Unknown actor", func(t *testing.T) {
+		req := signedRequest(t, privatePEM, remote.URL+"/users/mallory#main-key", target, body)
+		if _, err := federation.VerifyInboxRequest(req, body); err == nil {
+			t.Fatalf("Expected error for a key of an unknown actor, but got nil")
+		}
+	})
+}
+
+func TestRemoteRequestsStayPublic(t *testing.T) {
+	_, publicPEM := newTestKey(t)
+	remote := newRemoteActor(t, publicPEM)
+
+	t.Run("Loopback server", func(t *testing.T) {
+		federation := newTestFederation(t)
+		if _, err := federation.FetchActor(t.Context(), remote.URL+"/users/alice"); err == nil {
+			t.Fatalf("Expected error when fetching from a loopback address, but got nil")
+		}
+	})
+
+	t.Run("Private networks allowed", func(t *testing.T) {
+		federation := newTestFederation(t)
+		federation.AllowPrivateNetworks()
+		if _, err := federation.FetchActor(t.Context(), remote.URL+"/users/alice"); err != nil {
+			t.Fatalf("Expected the fetch to succeed, got %v", err)
+		}
+	})
+
+	t.Run("Refused addresses", func(t *testing.T) {
+		for _, address := range []string{
+			"127.0.0.1:443",
+			"[::1]:443",
+			"10.1.2.3:443",
+			"172.16.0.1:443",
+			"192.168.1.1:443",
+			"[fd00::1]:443",
+			"169.254.169.254:80",
+			"[fe80::1]:443",
+			"0.0.0.0:443",
+			"[::]:443",
+			"[::ffff:127.0.0.1]:443",
+		} {
+			if err := refusePrivateAddress("tcp", address, nil); err == nil {
+				t.Errorf("Expected %s to be refused", address)
+			}
+		}
+	})
+
+	t.Run("Public addresses", func(t *testing.T) {
+		for _, address := range []string{"93.184.216.34:443", "[2606:2800:220:1::1]:443"} {
+			if err := refusePrivateAddress("tcp", address, nil); err != nil {
+				t.Errorf("Expected %s to be allowed, got %v", address, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package activitypub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+)
+
+var (
+	// ErrInvalidAccount is returned for accounts not written as user@host.
+	ErrInvalidAccount = errors.New("account must be given as user@host")
+	// ErrNotAttributed is returned for Notes delivered by an actor other
+	// than their author.
+	ErrNotAttributed = errors.New("note is not attributed to the actor delivering it")
+)
+
+// Follow resolves account, written as user@host, through WebFinger and
+// queues a Follow of its actor on behalf of a local user. Notes of the
+// account are only kept once the remote server accepts the Follow.
+func (f *Federation) Follow(ctx context.Context, userID uuid.UUID, account string) (Actor, error) {
+	actorURI, err := f.lookupAccount(ctx, account)
+	if err != nil {
+		return Actor{}, err
+	}
+	actor, err := f.FetchActor(ctx, actorURI)
+	if err != nil {
+		return Actor{}, err
+	}
+
+	actorURL := f.ActorURL(userID)
+	followID := fmt.Sprintf("%s#follows/%s", actorURL, uuid.New())
+	payload, err := json.Marshal(Activity{
+		Context: ldContext,
+		ID:      followID,
+		Type:    "Follow",
+		Actor:   actorURL,
+		Object:  actor.ID,
+	})
+	if err != nil {
+		return Actor{}, err
+	}
+
+	err = f.db.UpsertRemoteFollow(ctx, database.UpsertRemoteFollowParams{
+		UserID:   userID,
+		ActorUri: actor.ID,
+		FollowID: followID,
+	})
+	if err != nil {
+		return Actor{}, err
+	}
+	err = f.db.EnqueueDelivery(ctx, database.EnqueueDeliveryParams{
+		SenderID: userID,
+		InboxUrl: actor.Inbox,
+		Payload:  string(payload),
+	})
+	return actor, err
+}
+
+// ConfirmFollow records that actor accepted a Follow sent by the local user.
+// Accepts of anything else are ignored.
+func (f *Federation) ConfirmFollow(ctx context.Context, userID uuid.UUID, accept IncomingActivity, actor Actor) error {
+	_, err := f.db.AcceptRemoteFollow(ctx, database.AcceptRemoteFollowParams{
+		UserID:   userID,
+		ActorUri: actor.ID,
+		FollowID: accept.ObjectID(),
+	})
+	return err
+}
+
+// StoreNote keeps the Note announced by a Create activity when the local
+// user follows the actor who delivered it. Other objects, and Notes of
+// actors the user doesn't follow, are ignored.
+func (f *Federation) StoreNote(ctx context.Context, userID uuid.UUID, create IncomingActivity, actor Actor) error {
+	var note Note
+	if json.Unmarshal(create.Object, &note) != nil || note.Type != "Note" {
+		return nil
+	}
+	if note.AttributedTo != actor.ID || !sameHost(note.ID, actor.ID) {
+		return ErrNotAttributed
+	}
+
+	following, err := f.db.IsFollowingRemoteActor(ctx, database.IsFollowingRemoteActorParams{
+		UserID:   userID,
+		ActorUri: actor.ID,
+	})
+	if err != nil || !following {
+		return err
+	}
+	return f.db.UpsertRemoteNote(ctx, database.UpsertRemoteNoteParams{
+		ID:          note.ID,
+		ActorUri:    actor.ID,
+		Content:     note.Content,
+		Url:         note.URL,
+		InReplyTo:   note.InReplyTo,
+		PublishedAt: note.Published.UTC(),
+	})
+}
+
+// DeleteNote drops a stored Note retracted by its author.
+func (f *Federation) DeleteNote(ctx context.Context, noteID string, actor Actor) error {
+	_, err := f.db.DeleteRemoteNote(ctx, database.DeleteRemoteNoteParams{
+		ID:       noteID,
+		ActorUri: actor.ID,
+	})
+	return err
+}
+
+// lookupAccount resolves user@host to the URI of its actor with WebFinger.
+func (f *Federation) lookupAccount(ctx context.Context, account string) (string, error) {
+	account = strings.TrimPrefix(account, "@")
+	username, host, ok := strings.Cut(account, "@")
+	if !ok || username == "" || host == "" || strings.ContainsAny(host, "/?#@") {
+		return "", ErrInvalidAccount
+	}
+
+	scheme := "https"
+	if f.allowInsecure {
+		scheme = "http"
+	}
+	lookup := url.URL{
+		Scheme:   scheme,
+		Host:     host,
+		Path:     "/.well-known/webfinger",
+		RawQuery: url.Values{"resource": {"acct:" + account}}.Encode(),
+	}
+	var jrd WebFinger
+	if err := f.fetchJSON(ctx, lookup.String(), JRDContentType, &jrd); err != nil {
+		return "", err
+	}
+	for _, link := range jrd.Links {
+		if link.Rel == "self" && (link.Type == ContentType || link.Type == LDContentType) {
+			return link.Href, nil
+		}
+	}
+	return "", fmt.Errorf("account %s has no actor", account)
+}
+
+func sameHost(a, b string) bool {
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	return errA == nil && errB == nil && ua.Host != "" && strings.EqualFold(ua.Host, ub.Host)
+}
@@ -0,0 +1,97 @@
+package activitypub
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testKeyID = "https://remote.example/users/alice#main-key"
+
+func newTestKey(t *testing.T) (privatePEM, publicPEM string) {
+	t.Helper()
+	privatePEM, publicPEM, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return privatePEM, publicPEM
+}
+
+func signedRequest(t *testing.T, privatePEM, keyID, target string, body []byte) *http.Request {
+	t.Helper()
+	key, err := ParsePrivateKey(privatePEM)
+	if err != nil {
+		t.Fatalf("Failed to parse private key: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	req.Header.Set("Content-Type", ContentType)
+	if err := SignRequest(req, keyID, key, body); err != nil {
+		t.Fatalf("Failed to sign request: %v", err)
+	}
+	return req
+}
+
+func TestSignAndVerifyRequest(t *testing.T) {
+	privatePEM, publicPEM := newTestKey(t)
+	_, otherPublicPEM := newTestKey(t)
+	body := []byte(`{"type":"Follow"}`)
+	const target = "https://chirpy.example/ap/users/bob/inbox"
+
+	verify := func(req *http.Request, body []byte, publicPEM string) (string, error) {
+		return VerifyRequest(req, body, func(string) (*rsa.PublicKey, error) {
+			return ParsePublicKey(publicPEM)
+		})
+	}
+
+	t.Run("Valid signature", func(t *testing.T) {
+		req := signedRequest(t, privatePEM, testKeyID, target, body)
+		keyID, err := verify(req, body, publicPEM)
+		if err != nil {
+			t.Fatalf("Verification failed: %v", err)
+		}
+		if keyID != testKeyID {
+			t.Fatalf("Expected key %q, got %q", testKeyID, keyID)
+		}
+	})
+
+	t.Run("Wrong key", func(t *testing.T) {
+		req := signedRequest(t, privatePEM, testKeyID, target, body)
+		if _, err := verify(req, body, otherPublicPEM); err == nil {
+			t.Fatalf("Expected error for a different key, but got nil")
+		}
+	})
+
+	t.Run("Tampered body", func(t *testing.T) {
+		req := signedRequest(t, privatePEM, testKeyID, target, body)
+		if _, err := verify(req, []byte(`{"type":"Undo"}`), publicPEM); err == nil {
+			t.Fatalf("Expected error for a tampered body, but got nil")
+		}
+	})
+
+	t.Run("Tampered target", func(t *testing.T) {
+		req := signedRequest(t, privatePEM, testKeyID, target, body)
+		req.URL.Path = "/ap/users/other/inbox"
+		if _, err := verify(req, body, publicPEM); err == nil {
+			t.Fatalf("Expected error for a tampered target, but got nil")
+		}
+	})
+
+	t.Run("Stale date", func(t *testing.T) {
+		req := signedRequest(t, privatePEM, testKeyID, target, body)
+		req.Header.Set("Date", time.Now().Add(-2*MaxClockSkew).UTC().Format(http.TimeFormat))
+		if _, err := verify(req, body, publicPEM); err == nil {
+			t.Fatalf("Expected error for a stale date, but got nil")
+		}
+	})
+
+	t.Run("Unsigned", func(t *testing.T) {
+		req := signedRequest(t, privatePEM, testKeyID, target, body)
+		req.Header.Del("Signature")
+		if _, err := verify(req, body, publicPEM); err == nil {
+			t.Fatalf("Expected error for an unsigned request, but got nil")
+		}
+	})
+}
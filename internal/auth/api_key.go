@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// GetAPIKey extracts the key from an "Authorization: ApiKey <key>" header.
+func GetAPIKey(headers http.Header) (string, error) {
+	authHeader := headers.Get("Authorization")
+	if authHeader == "" {
+		return "", errors.New("Authorization key is not present")
+	}
+	key, ok := strings.CutPrefix(authHeader, "ApiKey ")
+	if !ok || key == "" {
+		return "", errors.New("invalid api key")
+	}
+	return key, nil
+}
@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// KeySet signs access tokens with its active key and validates tokens signed
+// by any key it holds, selected through the kid header. Tokens without a kid
+// are checked against the legacy HS256 secret, if one is configured, so that
+// tokens issued before a rotation keep working until they expire.
+type KeySet struct {
+	active       *signingKey
+	keys         map[string]*signingKey
+	jwks         JWKS
+	legacySecret string
+}
+
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	jwk     JWK
+}
+
+// JWK is the public half of a signing key, as published in a JWKS document.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewKeySet builds a key set from RSA or Ed25519 private keys. The first key
+// signs new tokens; the others are only used for validation. With no keys,
+// tokens are signed with the legacy HS256 secret.
+func NewKeySet(legacySecret string, signers ...crypto.Signer) (*KeySet, error) {
+	if legacySecret == "" && len(signers) == 0 {
+		return nil, errors.New("a JWT secret or at least one signing key is required")
+	}
+
+	ks := &KeySet{
+		keys:         map[string]*signingKey{},
+		jwks:         JWKS{Keys: []JWK{}},
+		legacySecret: legacySecret,
+	}
+	for _, signer := range signers {
+		key, err := newSigningKey(signer)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := ks.keys[key.kid]; exists {
+			return nil, fmt.Errorf("duplicate signing key %s", key.kid)
+		}
+		ks.keys[key.kid] = key
+		ks.jwks.Keys = append(ks.jwks.Keys, key.jwk)
+		if ks.active == nil {
+			ks.active = key
+		}
+	}
+	return ks, nil
+}
+
+// LoadKeySet reads PEM encoded private keys from paths, in order of
+// preference, and builds a KeySet from them.
+func LoadKeySet(legacySecret string, paths []string) (*KeySet, error) {
+	signers := make([]crypto.Signer, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ParsePrivateKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		signers = append(signers, signer)
+	}
+	return NewKeySet(legacySecret, signers...)
+}
+
+// ParsePrivateKeyPEM decodes a PKCS#8 or PKCS#1 private key.
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("key cannot be used for signing")
+	}
+	return signer, nil
+}
+
+func (ks *KeySet) MakeJWT(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	if ks.active == nil {
+		return MakeJWT(userID, ks.legacySecret, expiresIn)
+	}
+
+	token := jwt.NewWithClaims(ks.active.method, jwt.RegisteredClaims{
+		Issuer:    "chirpy",
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+		Subject:   userID.String(),
+	})
+	token.Header["kid"] = ks.active.kid
+
+	return token.SignedString(ks.active.private)
+}
+
+func (ks *KeySet) ValidateJWT(tokenString string) (uuid.UUID, error) {
+	claims := &jwt.RegisteredClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, ks.keyFunc,
+		jwt.WithValidMethods([]string{"RS256", "EdDSA", "HS256"}))
+
+	if err != nil || !token.Valid {
+		return uuid.Nil, err
+	}
+
+	userId, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, errors.New("invalid token subject")
+	}
+
+	return userId, nil
+}
+
+func (ks *KeySet) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if ks.legacySecret == "" || token.Method != jwt.SigningMethodHS256 {
+			return nil, errors.New("token has no key id")
+		}
+		return []byte(ks.legacySecret), nil
+	}
+
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s for key %s", token.Method.Alg(), kid)
+	}
+	return key.private.Public(), nil
+}
+
+// JWKS returns the public keys of the set, active key first. The legacy
+// secret is never published.
+func (ks *KeySet) JWKS() JWKS {
+	return ks.jwks
+}
+
+func newSigningKey(signer crypto.Signer) (*signingKey, error) {
+	key := &signingKey{private: signer}
+	// The members of each thumbprint input are in the lexicographic order
+	// required by RFC 7638.
+	var thumbprintInput any
+
+	switch pub := signer.Public().(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		key.method = jwt.SigningMethodRS256
+		key.jwk = JWK{
+			Kty: "RSA",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}
+		thumbprintInput = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{key.jwk.E, key.jwk.Kty, key.jwk.N}
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+		key.jwk = JWK{
+			Kty: "OKP",
+			Alg: "EdDSA",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		}
+		thumbprintInput = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{key.jwk.Crv, key.jwk.Kty, key.jwk.X}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", pub)
+	}
+
+	data, err := json.Marshal(thumbprintInput)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	key.kid = base64.RawURLEncoding.EncodeToString(sum[:])
+	key.jwk.Kid = key.kid
+	key.jwk.Use = "sig"
+	return key, nil
+}
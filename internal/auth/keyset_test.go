@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func generateKeys(t *testing.T) (crypto.Signer, crypto.Signer) {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	return rsaKey, edKey
+}
+
+func TestKeySetFlow(t *testing.T) {
+	rsaKey, edKey := generateKeys(t)
+
+	for name, signer := range map[string]crypto.Signer{"RS256": rsaKey, "EdDSA": edKey} {
+		t.Run(name, func(t *testing.T) {
+			ks, err := NewKeySet("", signer)
+			if err != nil {
+				t.Fatalf("Failed to build key set: %v", err)
+			}
+
+			token, err := ks.MakeJWT(userID, expiry)
+			if err != nil {
+				t.Fatalf("Failed to make JWT: %v", err)
+			}
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+			if err != nil {
+				t.Fatalf("Failed to parse JWT: %v", err)
+			}
+			if parsed.Method.Alg() != name || parsed.Header["kid"] != ks.JWKS().Keys[0].Kid {
+				t.Fatalf("Unexpected header %v", parsed.Header)
+			}
+
+			validatedID, err := ks.ValidateJWT(token)
+			if err != nil {
+				t.Fatalf("Validation Failed: %v", err)
+			}
+			if validatedID != userID {
+				t.Fatalf("Expected UUID %v, got %v", userID, validatedID)
+			}
+		})
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	rsaKey, edKey := generateKeys(t)
+
+	oldSet, _ := NewKeySet(secret, rsaKey)
+	oldToken, _ := oldSet.MakeJWT(userID, expiry)
+	legacyToken, _ := MakeJWT(userID, secret, expiry)
+
+	rotated, err := NewKeySet(secret, edKey, rsaKey)
+	if err != nil {
+		t.Fatalf("Failed to build key set: %v", err)
+	}
+	newToken, _ := rotated.MakeJWT(userID, expiry)
+
+	for name, token := range map[string]string{"old key": oldToken, "new key": newToken, "legacy secret": legacyToken} {
+		if _, err := rotated.ValidateJWT(token); err != nil {
+			t.Fatalf("Expected token signed with %s to validate, got %v", name, err)
+		}
+	}
+
+	retired, _ := NewKeySet("", edKey)
+	if _, err := retired.ValidateJWT(oldToken); err == nil {
+		t.Fatalf("Expected error for token signed with a retired key, but got nil")
+	}
+	if _, err := retired.ValidateJWT(legacyToken); err == nil {
+		t.Fatalf("Expected error for legacy token without a secret, but got nil")
+	}
+}
+
+func TestKeySetRejectsAlgorithmMismatch(t *testing.T) {
+	rsaKey, _ := generateKeys(t)
+	ks, _ := NewKeySet("", rsaKey)
+	kid := ks.JWKS().Keys[0].Kid
+
+	// An HS256 token keyed with the public modulus must not pass as RS256.
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: userID.String()})
+	token.Header["kid"] = kid
+	signed, _ := token.SignedString([]byte(ks.JWKS().Keys[0].N))
+
+	if _, err := ks.ValidateJWT(signed); err == nil {
+		t.Fatalf("Expected error for mismatched algorithm, but got nil")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey, edKey := generateKeys(t)
+	ks, _ := NewKeySet(secret, rsaKey, edKey)
+
+	keys := ks.JWKS().Keys
+	if len(keys) != 2 {
+		t.Fatalf("Expected 2 keys, got %d", len(keys))
+	}
+	if keys[0].Kty != "RSA" || keys[0].N == "" || keys[0].E != "AQAB" {
+		t.Fatalf("Unexpected RSA key %+v", keys[0])
+	}
+	if keys[1].Kty != "OKP" || keys[1].Crv != "Ed25519" || keys[1].X == "" {
+		t.Fatalf("Unexpected Ed25519 key %+v", keys[1])
+	}
+}
+
+func TestParsePrivateKeyPEM(t *testing.T) {
+	_, edKey := generateKeys(t)
+	der, _ := x509.MarshalPKCS8PrivateKey(edKey)
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	signer, err := ParsePrivateKeyPEM(data)
+	if err != nil {
+		t.Fatalf("Failed to parse key: %v", err)
+	}
+	if !edKey.Public().(ed25519.PublicKey).Equal(signer.Public()) {
+		t.Fatalf("Parsed key does not match")
+	}
+}
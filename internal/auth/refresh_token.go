@@ -2,6 +2,7 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 )
 
@@ -10,3 +11,10 @@ func MakeRefreshToken() (string, error) {
 	n, _ := rand.Read(data)
 	return hex.EncodeToString(data[:n]), nil
 }
+
+// HashRefreshToken returns the value stored in the database for a refresh
+// token, so that a leaked table does not hand out usable tokens.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
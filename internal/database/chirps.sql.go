@@ -7,23 +7,34 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createChirpy = `-- name: CreateChirpy :one
-INSERT INTO chirps (id, body, user_id, created_at, updated_at)
-VALUES (gen_random_uuid(), $1, $2, NOW(), NOW())
-RETURNING id, body, user_id, created_at, updated_at
+INSERT INTO chirps (id, body, user_id, in_reply_to, kind, referenced_chirp_id, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, NOW(), NOW())
+RETURNING id, body, user_id, created_at, updated_at, in_reply_to, reply_count, deleted_at, kind, referenced_chirp_id, search_vector
 `
 
 type CreateChirpyParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body              string
+	UserID            uuid.UUID
+	InReplyTo         uuid.NullUUID
+	Kind              string
+	ReferencedChirpID uuid.NullUUID
 }
 
 func (q *Queries) CreateChirpy(ctx context.Context, arg CreateChirpyParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirpy, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirpy,
+		arg.Body,
+		arg.UserID,
+		arg.InReplyTo,
+		arg.Kind,
+		arg.ReferencedChirpID,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -31,12 +42,97 @@ func (q *Queries) CreateChirpy(ctx context.Context, arg CreateChirpyParams) (Chi
 		&i.UserID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.InReplyTo,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.Kind,
+		&i.ReferencedChirpID,
+		&i.SearchVector,
 	)
 	return i, err
 }
 
+const decrementReplyCount = `-- name: DecrementReplyCount :exec
+UPDATE chirps
+SET reply_count = GREATEST(reply_count - 1, 0)
+WHERE id = $1
+`
+
+func (q *Queries) DecrementReplyCount(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, decrementReplyCount, id)
+	return err
+}
+
+const deleteChirpy = `-- name: DeleteChirpy :execrows
+UPDATE chirps
+SET body = '', deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+// Deleted chirps are kept as tombstones so that their replies keep their
+// place in the thread. Affects no rows when the chirp was already deleted.
+func (q *Queries) DeleteChirpy(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteChirpy, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getChirpAncestors = `-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors (id, in_reply_to, depth) AS (
+    SELECT c.id, c.in_reply_to, 0 FROM chirps c
+    WHERE c.id = $1
+    UNION ALL
+    SELECT c.id, c.in_reply_to, a.depth + 1 FROM chirps c
+    JOIN ancestors a ON c.id = a.in_reply_to
+)
+SELECT chirps.id, chirps.body, chirps.user_id, chirps.created_at, chirps.updated_at, chirps.in_reply_to, chirps.reply_count, chirps.deleted_at, chirps.kind, chirps.referenced_chirp_id, chirps.search_vector FROM chirps
+JOIN ancestors ON ancestors.id = chirps.id
+WHERE ancestors.depth > 0
+ORDER BY ancestors.depth DESC
+`
+
+// Walks up the in_reply_to chain of a chirp and returns its ancestors, root
+// first.
+func (q *Queries) GetChirpAncestors(ctx context.Context, id uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpAncestors, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.Body,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.Kind,
+			&i.ReferencedChirpID,
+			&i.SearchVector,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getChirps = `-- name: GetChirps :many
-SELECT id, body, user_id, created_at, updated_at FROM chirps
+SELECT id, body, user_id, created_at, updated_at, in_reply_to, reply_count, deleted_at, kind, referenced_chirp_id, search_vector FROM chirps
+WHERE deleted_at IS NULL
 ORDER BY created_at ASC
 `
 
@@ -55,6 +151,52 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UserID,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.Kind,
+			&i.ReferencedChirpID,
+			&i.SearchVector,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, body, user_id, created_at, updated_at, in_reply_to, reply_count, deleted_at, kind, referenced_chirp_id, search_vector FROM chirps
+WHERE id = ANY($1::uuid[])
+`
+
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.Body,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.Kind,
+			&i.ReferencedChirpID,
+			&i.SearchVector,
 		); err != nil {
 			return nil, err
 		}
@@ -70,7 +212,7 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpyByID = `-- name: GetChirpyByID :one
-SELECT id, body, user_id, created_at, updated_at FROM chirps
+SELECT id, body, user_id, created_at, updated_at, in_reply_to, reply_count, deleted_at, kind, referenced_chirp_id, search_vector FROM chirps
 WHERE id = $1 LIMIT 1
 `
 
@@ -83,6 +225,413 @@ func (q *Queries) GetChirpyByID(ctx context.Context, id uuid.UUID) (Chirp, error
 		&i.UserID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.InReplyTo,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.Kind,
+		&i.ReferencedChirpID,
+		&i.SearchVector,
+	)
+	return i, err
+}
+
+const getLastChirpChange = `-- name: GetLastChirpChange :one
+SELECT MAX(updated_at)::timestamp AS last_changed FROM chirps
+WHERE ($1::uuid IS NULL OR user_id = $1::uuid)
+AND (
+    $2::text IS NULL
+    OR EXISTS (
+        SELECT 1 FROM chirp_tags
+        JOIN tags ON tags.id = chirp_tags.tag_id
+        WHERE chirp_tags.chirp_id = chirps.id AND tags.name = $2::text
+    )
+)
+`
+
+type GetLastChirpChangeParams struct {
+	AuthorID uuid.NullUUID
+	Tag      sql.NullString
+}
+
+// Returns when a chirp of the author, or with the tag, last changed. Deleting
+// a chirp sets its updated_at, so deletions count as changes.
+func (q *Queries) GetLastChirpChange(ctx context.Context, arg GetLastChirpChangeParams) (sql.NullTime, error) {
+	row := q.db.QueryRowContext(ctx, getLastChirpChange, arg.AuthorID, arg.Tag)
+	var last_changed sql.NullTime
+	err := row.Scan(&last_changed)
+	return last_changed, err
+}
+
+const incrementReplyCount = `-- name: IncrementReplyCount :exec
+UPDATE chirps
+SET reply_count = reply_count + 1
+WHERE id = $1
+`
+
+func (q *Queries) IncrementReplyCount(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, incrementReplyCount, id)
+	return err
+}
+
+const listChirpsAsc = `-- name: ListChirpsAsc :many
+SELECT id, body, user_id, created_at, updated_at, in_reply_to, reply_count, deleted_at, kind, referenced_chirp_id, search_vector FROM chirps
+WHERE deleted_at IS NULL
+AND ($1::uuid IS NULL OR user_id = $1::uuid)
+AND NOT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = $2::uuid AND blocked_id = user_id)
+AND NOT EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = $2::uuid AND muted_id = user_id)
+AND (
+    $3::timestamp IS NULL
+    OR (created_at, id) > ($3::timestamp, $4::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT $5
+`
+
+type ListChirpsAscParams struct {
+	AuthorID        uuid.NullUUID
+	ViewerID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	Limit           int32
+}
+
+func (q *Queries) ListChirpsAsc(ctx context.Context, arg ListChirpsAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsAsc,
+		arg.AuthorID,
+		arg.ViewerID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.Body,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.Kind,
+			&i.ReferencedChirpID,
+			&i.SearchVector,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+SELECT id, body, user_id, created_at, updated_at, in_reply_to, reply_count, deleted_at, kind, referenced_chirp_id, search_vector FROM chirps
+WHERE deleted_at IS NULL
+AND ($1::uuid IS NULL OR user_id = $1::uuid)
+AND NOT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = $2::uuid AND blocked_id = user_id)
+AND NOT EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = $2::uuid AND muted_id = user_id)
+AND (
+    $3::timestamp IS NULL
+    OR (created_at, id) < ($3::timestamp, $4::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT $5
+`
+
+type ListChirpsDescParams struct {
+	AuthorID        uuid.NullUUID
+	ViewerID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	Limit           int32
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc,
+		arg.AuthorID,
+		arg.ViewerID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.Body,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.Kind,
+			&i.ReferencedChirpID,
+			&i.SearchVector,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listReplyTree = `-- name: ListReplyTree :many
+WITH RECURSIVE tree (id, depth, path) AS (
+    SELECT c.id, 1, ARRAY[to_char(c.created_at, 'YYYYMMDDHH24MISSUS') || c.id::text]
+    FROM chirps c
+    WHERE c.in_reply_to = $1
+    AND NOT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = $2::uuid AND blocked_id = c.user_id)
+    AND NOT EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = $2::uuid AND muted_id = c.user_id)
+    UNION ALL
+    SELECT c.id, t.depth + 1, t.path || (to_char(c.created_at, 'YYYYMMDDHH24MISSUS') || c.id::text)
+    FROM chirps c
+    JOIN tree t ON c.in_reply_to = t.id
+    WHERE NOT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = $2::uuid AND blocked_id = c.user_id)
+    AND NOT EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = $2::uuid AND muted_id = c.user_id)
+)
+SELECT chirps.id, chirps.body, chirps.user_id, chirps.created_at, chirps.updated_at, chirps.in_reply_to, chirps.reply_count, chirps.deleted_at, chirps.kind, chirps.referenced_chirp_id, chirps.search_vector, tree.depth FROM tree
+JOIN chirps ON chirps.id = tree.id
+WHERE (
+    $3::uuid IS NULL
+    OR tree.path > (SELECT seen.path FROM tree seen WHERE seen.id = $3::uuid)
+)
+ORDER BY tree.path
+LIMIT $4
+`
+
+type ListReplyTreeParams struct {
+	ChirpID  uuid.UUID
+	ViewerID uuid.NullUUID
+	CursorID uuid.NullUUID
+	Limit    int32
+}
+
+type ListReplyTreeRow struct {
+	Chirp Chirp
+	Depth int32
+}
+
+// Returns the replies below a chirp, at any depth, in depth-first order:
+// every reply is followed by its own replies, siblings being ordered by
+// (created_at, id). Pages continue after the reply given as the cursor.
+// Replies by users the viewer blocked or muted are left out along with the
+// replies below them.
+func (q *Queries) ListReplyTree(ctx context.Context, arg ListReplyTreeParams) ([]ListReplyTreeRow, error) {
+	rows, err := q.db.QueryContext(ctx, listReplyTree,
+		arg.ChirpID,
+		arg.ViewerID,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListReplyTreeRow
+	for rows.Next() {
+		var i ListReplyTreeRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.ReplyCount,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.Kind,
+			&i.Chirp.ReferencedChirpID,
+			&i.Chirp.SearchVector,
+			&i.Depth,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTimelineAsc = `-- name: ListTimelineAsc :many
+SELECT chirps.id, chirps.body, chirps.user_id, chirps.created_at, chirps.updated_at, chirps.in_reply_to, chirps.reply_count, chirps.deleted_at, chirps.kind, chirps.referenced_chirp_id, chirps.search_vector FROM chirps
+JOIN follows ON follows.followee_id = chirps.user_id
+WHERE follows.follower_id = $1
+AND chirps.deleted_at IS NULL
+AND NOT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = $1 AND blocked_id = chirps.user_id)
+AND NOT EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = $1 AND muted_id = chirps.user_id)
+AND (
+    $2::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > ($2::timestamp, $3::uuid)
+)
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT $4
+`
+
+type ListTimelineAscParams struct {
+	FollowerID      uuid.UUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	Limit           int32
+}
+
+func (q *Queries) ListTimelineAsc(ctx context.Context, arg ListTimelineAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listTimelineAsc,
+		arg.FollowerID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.Body,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.Kind,
+			&i.ReferencedChirpID,
+			&i.SearchVector,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTimelineDesc = `-- name: ListTimelineDesc :many
+SELECT chirps.id, chirps.body, chirps.user_id, chirps.created_at, chirps.updated_at, chirps.in_reply_to, chirps.reply_count, chirps.deleted_at, chirps.kind, chirps.referenced_chirp_id, chirps.search_vector FROM chirps
+JOIN follows ON follows.followee_id = chirps.user_id
+WHERE follows.follower_id = $1
+AND chirps.deleted_at IS NULL
+AND NOT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = $1 AND blocked_id = chirps.user_id)
+AND NOT EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = $1 AND muted_id = chirps.user_id)
+AND (
+    $2::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < ($2::timestamp, $3::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT $4
+`
+
+type ListTimelineDescParams struct {
+	FollowerID      uuid.UUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	Limit           int32
+}
+
+func (q *Queries) ListTimelineDesc(ctx context.Context, arg ListTimelineDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listTimelineDesc,
+		arg.FollowerID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.Body,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.Kind,
+			&i.ReferencedChirpID,
+			&i.SearchVector,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateChirpy = `-- name: UpdateChirpy :one
+UPDATE chirps
+SET body = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, body, user_id, created_at, updated_at, in_reply_to, reply_count, deleted_at, kind, referenced_chirp_id, search_vector
+`
+
+type UpdateChirpyParams struct {
+	ID   uuid.UUID
+	Body string
+}
+
+func (q *Queries) UpdateChirpy(ctx context.Context, arg UpdateChirpyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpy, arg.ID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.Body,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.InReplyTo,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.Kind,
+		&i.ReferencedChirpID,
+		&i.SearchVector,
 	)
 	return i, err
 }
@@ -11,21 +11,159 @@ import (
 	"github.com/google/uuid"
 )
 
+type ActorKey struct {
+	UserID        uuid.UUID
+	PrivateKeyPem string
+	PublicKeyPem  string
+	CreatedAt     time.Time
+}
+
+type ApDelivery struct {
+	ID            int64
+	SenderID      uuid.UUID
+	InboxUrl      string
+	Payload       string
+	Attempts      int32
+	NextAttemptAt time.Time
+	LastError     string
+	DeliveredAt   sql.NullTime
+	FailedAt      sql.NullTime
+	CreatedAt     time.Time
+}
+
 type Chirp struct {
+	ID                uuid.UUID
+	Body              string
+	UserID            uuid.UUID
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	InReplyTo         uuid.NullUUID
+	ReplyCount        int32
+	DeletedAt         sql.NullTime
+	Kind              string
+	ReferencedChirpID uuid.NullUUID
+	SearchVector      interface{}
+}
+
+type ChirpMedium struct {
+	ChirpID  uuid.UUID
+	MediaID  uuid.UUID
+	Position int32
+	AltText  string
+}
+
+type ChirpTag struct {
+	ChirpID uuid.UUID
+	TagID   uuid.UUID
+}
+
+type Conversation struct {
 	ID        uuid.UUID
-	Body      string
-	UserID    uuid.UUID
+	DirectKey string
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
-type RefreshToken struct {
-	Token     string
+type ConversationParticipant struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	LastReadAt     sql.NullTime
+	CreatedAt      time.Time
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Medium struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	ContentType  string
+	Width        int32
+	Height       int32
+	SizeBytes    int32
+	StorageKey   string
+	ThumbnailKey string
+	CreatedAt    time.Time
+}
+
+type Mention struct {
+	ChirpID   uuid.UUID
 	UserID    uuid.UUID
-	ExpiresAt time.Time
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	RevokedAt sql.NullTime
+}
+
+type Message struct {
+	ID             uuid.UUID
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+	CreatedAt      time.Time
+}
+
+type Notification struct {
+	ID        int64
+	UserID    uuid.UUID
+	Kind      string
+	ActorID   uuid.UUID
+	ChirpID   uuid.NullUUID
+	Emoji     sql.NullString
+	CreatedAt time.Time
+}
+
+type Reaction struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	Emoji     string
+	CreatedAt time.Time
+}
+
+type RefreshToken struct {
+	Token      string
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	RevokedAt  sql.NullTime
+	FamilyID   uuid.UUID
+	ReplacedBy sql.NullString
+	UserAgent  string
+	IpAddress  string
+	LastUsedAt time.Time
+}
+
+type RemoteFollow struct {
+	UserID     uuid.UUID
+	ActorUri   string
+	FollowID   string
+	AcceptedAt sql.NullTime
+	CreatedAt  time.Time
+}
+
+type RemoteFollower struct {
+	UserID         uuid.UUID
+	ActorUri       string
+	InboxUrl       string
+	SharedInboxUrl string
+	CreatedAt      time.Time
+}
+
+type RemoteNote struct {
+	ID          string
+	ActorUri    string
+	Content     string
+	Url         string
+	InReplyTo   string
+	PublishedAt time.Time
+	ReceivedAt  time.Time
+}
+
+type Tag struct {
+	ID        uuid.UUID
+	Name      string
+	CreatedAt time.Time
 }
 
 type User struct {
@@ -34,4 +172,47 @@ type User struct {
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 	HashedPassword string
+	IsChirpyRed    bool
+	Role           string
+	Username       sql.NullString
+	DisplayName    string
+	Bio            string
+	AvatarUrl      string
+	Location       string
+	Website        string
+}
+
+type UserBlock struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type UserMute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type Webhook struct {
+	ID        uuid.UUID
+	Url       string
+	Events    []string
+	Secret    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type WebhookDelivery struct {
+	ID             int64
+	WebhookID      uuid.UUID
+	Event          string
+	Payload        string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	ResponseStatus sql.NullInt32
+	LastError      string
+	DeliveredAt    sql.NullTime
+	FailedAt       sql.NullTime
+	CreatedAt      time.Time
 }
@@ -0,0 +1,168 @@
+package entities
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	TypeHashtag = "hashtag"
+	TypeMention = "mention"
+	TypeURL     = "url"
+
+	maxTagLength      = 100
+	minUsernameLength = 3
+	maxUsernameLength = 20
+)
+
+var urlSchemes = []string{"http://", "https://"}
+
+// Entity is a span of a chirp body with a special meaning. Start and End are
+// rune offsets into the body, End being exclusive. Text leaves out the
+// leading '#' or '@'.
+type Entity struct {
+	Type  string
+	Text  string
+	Start int
+	End   int
+}
+
+// Parse finds the URLs, hashtags and mentions in body, in order of
+// appearance. Hashtags and mentions start with a '#' or '@' that doesn't
+// follow a word character, so neither a#b nor an email address match, and
+// nothing inside a URL is parsed any further.
+func Parse(body string) []Entity {
+	runes := []rune(body)
+	var found []Entity
+
+	for i := 0; i < len(runes); i++ {
+		if i > 0 && isWordRune(runes[i-1]) {
+			continue
+		}
+
+		if end := urlEnd(runes, i); end > i {
+			found = append(found, Entity{Type: TypeURL, Text: string(runes[i:end]), Start: i, End: end})
+			i = end - 1
+			continue
+		}
+
+		var entityType string
+		var isNameRune func(rune) bool
+		switch runes[i] {
+		case '#':
+			entityType, isNameRune = TypeHashtag, isWordRune
+		case '@':
+			entityType, isNameRune = TypeMention, isUsernameRune
+		default:
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && isNameRune(runes[end]) {
+			end++
+		}
+		text := string(runes[i+1 : end])
+		if !validName(entityType, text) || (end < len(runes) && isWordRune(runes[end])) {
+			continue
+		}
+		found = append(found, Entity{Type: entityType, Text: text, Start: i, End: end})
+		i = end - 1
+	}
+	return found
+}
+
+// Hashtags returns the normalized names of the distinct hashtags in body.
+func Hashtags(body string) []string {
+	return distinct(body, TypeHashtag, NormalizeTag)
+}
+
+// Mentions returns the distinct usernames mentioned in body, lowercased.
+func Mentions(body string) []string {
+	return distinct(body, TypeMention, strings.ToLower)
+}
+
+// NormalizeTag maps the spellings of a hashtag to the name it is stored
+// under, so #Go and #go end up on the same tag page.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
+
+// IsValidUsername reports whether name can be registered as a username:
+// 3 to 20 ASCII letters, digits or underscores.
+func IsValidUsername(name string) bool {
+	if len(name) < minUsernameLength || len(name) > maxUsernameLength {
+		return false
+	}
+	for _, r := range name {
+		if !isUsernameRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func distinct(body, entityType string, normalize func(string) string) []string {
+	var values []string
+	seen := map[string]bool{}
+	for _, entity := range Parse(body) {
+		if entity.Type != entityType {
+			continue
+		}
+		value := normalize(entity.Text)
+		if !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func validName(entityType, text string) bool {
+	switch entityType {
+	case TypeHashtag:
+		return len([]rune(text)) <= maxTagLength && strings.ContainsFunc(text, isTagLetter)
+	case TypeMention:
+		return text != "" && len(text) <= maxUsernameLength
+	}
+	return false
+}
+
+// urlEnd returns the end of the http(s) URL starting at start, or start when
+// there is none. Trailing punctuation is left out, since it usually belongs
+// to the sentence rather than to the URL.
+func urlEnd(runes []rune, start int) int {
+	rest := strings.ToLower(string(runes[start:min(start+len("https://"), len(runes))]))
+	schemeLength := 0
+	for _, scheme := range urlSchemes {
+		if strings.HasPrefix(rest, scheme) {
+			schemeLength = len(scheme)
+		}
+	}
+	if schemeLength == 0 {
+		return start
+	}
+
+	end := start + schemeLength
+	for end < len(runes) && !unicode.IsSpace(runes[end]) {
+		end++
+	}
+	for end > start+schemeLength && strings.ContainsRune(".,;:!?'\")]", runes[end-1]) {
+		end--
+	}
+	if end == start+schemeLength {
+		return start
+	}
+	return end
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isTagLetter(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isUsernameRune(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
@@ -0,0 +1,69 @@
+package entities
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseHashtags(t *testing.T) {
+	body := "#Go is fun, #golang#not and a#b #123 #ça_va!"
+	expected := []Entity{
+		{Type: TypeHashtag, Text: "Go", Start: 0, End: 3},
+		{Type: TypeHashtag, Text: "golang", Start: 12, End: 19},
+		{Type: TypeHashtag, Text: "ça_va", Start: 37, End: 43},
+	}
+
+	got := Parse(body)
+	if !slices.Equal(got, expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestParseMentionsAndURLs(t *testing.T) {
+	body := "hi @Alice, mail bob@example.com or see https://chirpy.dev/#top."
+	expected := []Entity{
+		{Type: TypeMention, Text: "Alice", Start: 3, End: 9},
+		{Type: TypeURL, Text: "https://chirpy.dev/#top", Start: 39, End: 62},
+	}
+
+	got := Parse(body)
+	if !slices.Equal(got, expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestHashtags(t *testing.T) {
+	got := Hashtags("#Go #go #GO #chirpy")
+	expected := []string{"go", "chirpy"}
+	if !slices.Equal(got, expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+
+	if tags := Hashtags("no tags here"); len(tags) != 0 {
+		t.Fatalf("Expected no tags, got %v", tags)
+	}
+}
+
+func TestMentions(t *testing.T) {
+	got := Mentions("@Bob and @bob met @carol_1 @waytoolongtobeausername")
+	expected := []string{"bob", "carol_1"}
+	if !slices.Equal(got, expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestIsValidUsername(t *testing.T) {
+	tests := map[string]bool{
+		"bob":                     true,
+		"Carol_1":                 true,
+		"al":                      false,
+		"dave!":                   false,
+		"josé":                    false,
+		"waytoolongtobeausername": false,
+	}
+	for name, valid := range tests {
+		if got := IsValidUsername(name); got != valid {
+			t.Errorf("IsValidUsername(%q) = %v, expected %v", name, got, valid)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package feeds
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() Feed {
+	published := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	return Feed{
+		Title:    "Chirps by @bob",
+		ID:       "https://chirpy.dev/users/1",
+		Link:     "https://chirpy.dev/users/1",
+		SelfLink: "https://chirpy.dev/feeds/users/1.atom",
+		Updated:  published,
+		Entries: []Entry{{
+			ID:        "https://chirpy.dev/api/chirps/2",
+			Title:     "fish & <chips>",
+			Link:      "https://chirpy.dev/api/chirps/2",
+			Content:   "fish & <chips>",
+			Author:    "bob",
+			Published: published,
+			Updated:   published,
+		}},
+	}
+}
+
+func TestAtom(t *testing.T) {
+	data, err := Atom(testFeed())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parsed atomFeed
+	if err := xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("Invalid XML: %v\n%s", err, data)
+	}
+	if len(parsed.Entries) != 1 || parsed.Entries[0].Content.Body != "fish & <chips>" {
+		t.Fatalf("Unexpected entries %+v", parsed.Entries)
+	}
+	if parsed.Updated != "2025-03-01T12:00:00Z" {
+		t.Fatalf("Unexpected updated %s", parsed.Updated)
+	}
+}
+
+func TestRSS(t *testing.T) {
+	data, err := RSS(testFeed())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := xml.Unmarshal(data, new(struct{})); err != nil {
+		t.Fatalf("Invalid XML: %v\n%s", err, data)
+	}
+	for _, expected := range []string{
+		`<rss version="2.0"`,
+		`<atom:link href="https://chirpy.dev/feeds/users/1.atom" rel="self"`,
+		`<dc:creator>bob</dc:creator>`,
+		`<pubDate>Sat, 01 Mar 2025 12:00:00 +0000</pubDate>`,
+		`fish &amp; &lt;chips&gt;`,
+	} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("Expected %q in\n%s", expected, data)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/sheltonFr/bootdev/chirspy/internal/activitypub"
+	"github.com/sheltonFr/bootdev/chirspy/internal/auth"
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+	"github.com/sheltonFr/bootdev/chirspy/internal/utils"
+)
+
+const (
+	outboxLength    = 20
+	maxActivitySize = 1 << 20
+)
+
+type activityPubHandler struct {
+	db         *database.Queries
+	logger     *log.Logger
+	keys       *auth.KeySet
+	federation *activitypub.Federation
+}
+
+func NewActivityPubHandler(db *database.Queries, logger *log.Logger, keys *auth.KeySet, federation *activitypub.Federation) *activityPubHandler {
+	return &activityPubHandler{db, logger, keys, federation}
+}
+
+// followRemoteDto names an account on another server as user@host.
+type followRemoteDto struct {
+	Account string `json:"account"`
+}
+
+type RemoteFollowResponse struct {
+	ActorURI string `json:"actor_uri"`
+}
+
+// WebFinger resolves acct:username@host resources to the actor of a local
+// user, which is how remote servers discover accounts.
+func (a *activityPubHandler) WebFinger(w http.ResponseWriter, r *http.Request) {
+	resource := r.URL.Query().Get("resource")
+	account, ok := strings.CutPrefix(resource, "acct:")
+	if !ok {
+		utils.RespondWithError(w, http.StatusBadRequest, "resource must be an acct: URI")
+		return
+	}
+	username, host, ok := strings.Cut(account, "@")
+	if !ok || !strings.EqualFold(host, a.federation.Host()) {
+		utils.RespondWithError(w, http.StatusNotFound, "Account not found")
+		return
+	}
+
+	user, err := a.db.GetUserByUsername(r.Context(), username)
+	if err != nil {
+		a.respondWithUserLookupError(w, err)
+		return
+	}
+
+	actorURL := a.federation.ActorURL(user.ID)
+	respondWithActivityJSON(w, http.StatusOK, activitypub.JRDContentType, activitypub.WebFinger{
+		Subject: "acct:" + user.Username.String + "@" + a.federation.Host(),
+		Aliases: []string{actorURL},
+		Links: []activitypub.WebFingerLink{
+			{Rel: "self", Type: activitypub.ContentType, Href: actorURL},
+		},
+	})
+}
+
+func (a *activityPubHandler) GetActor(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.getFederatedUser(w, r)
+	if !ok {
+		return
+	}
+	actor, err := a.federation.Actor(r.Context(), user)
+	if err != nil {
+		a.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve actor")
+		return
+	}
+	respondWithActivityJSON(w, http.StatusOK, activitypub.ContentType, actor)
+}
+
+// GetOutbox lists the Create activities of a user's latest chirps.
+func (a *activityPubHandler) GetOutbox(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.getFederatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	total, err := a.db.CountOutboxChirps(r.Context(), user.ID)
+	if err != nil {
+		a.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve outbox")
+		return
+	}
+	chirps, err := a.db.ListOutboxChirps(r.Context(), database.ListOutboxChirpsParams{
+		UserID: user.ID,
+		Limit:  outboxLength,
+	})
+	if err != nil {
+		a.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve outbox")
+		return
+	}
+
+	items := make([]any, len(chirps))
+	for i, chirp := range chirps {
+		activity := a.federation.CreateActivity(chirp)
+		activity.Context = nil
+		items[i] = activity
+	}
+	respondWithActivityJSON(w, http.StatusOK, activitypub.ContentType, activitypub.OrderedCollection{
+		Context:      "https://www.w3.org/ns/activitystreams",
+		ID:           a.federation.ActorURL(user.ID) + "/outbox",
+		Type:         "OrderedCollection",
+		TotalItems:   total,
+		OrderedItems: items,
+	})
+}
+
+// GetFollowers only reports how many followers a user has, local and remote;
+// who they are isn't published.
+func (a *activityPubHandler) GetFollowers(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.getFederatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	profile, err := a.db.GetUserProfile(r.Context(), user.ID)
+	if err != nil {
+		a.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve followers")
+		return
+	}
+	remote, err := a.db.CountRemoteFollowers(r.Context(), user.ID)
+	if err != nil {
+		a.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve followers")
+		return
+	}
+	respondWithActivityJSON(w, http.StatusOK, activitypub.ContentType, activitypub.OrderedCollection{
+		Context:      "https://www.w3.org/ns/activitystreams",
+		ID:           a.federation.ActorURL(user.ID) + "/followers",
+		Type:         "OrderedCollection",
+		TotalItems:   profile.FollowerCount + remote,
+		OrderedItems: []any{},
+	})
+}
+
+// GetNote serves the Note of a chirp, or a Tombstone with 410 Gone once it
+// has been deleted.
+func (a *activityPubHandler) GetNote(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseChirpID(w, r)
+	if !ok {
+		return
+	}
+
+	chirp, err := a.db.GetChirpyByID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, http.StatusNotFound, "Chirp Not Found")
+			return
+		}
+		a.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve chirp")
+		return
+	}
+	if chirp.DeletedAt.Valid {
+		respondWithActivityJSON(w, http.StatusGone, activitypub.ContentType, activitypub.Tombstone{
+			ID:   a.federation.NoteURL(chirp.ID),
+			Type: "Tombstone",
+		})
+		return
+	}
+	if !activitypub.Federates(chirp) {
+		utils.RespondWithError(w, http.StatusNotFound, "Chirp Not Found")
+		return
+	}
+
+	note := a.federation.Note(chirp)
+	note.Context = "https://www.w3.org/ns/activitystreams"
+	respondWithActivityJSON(w, http.StatusOK, activitypub.ContentType, note)
+}
+
+// FollowRemote makes the caller follow an account on another server. The
+// Follow is delivered in the background; the account's Notes are kept once
+// its server accepts it.
+func (a *activityPubHandler) FollowRemote(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticateRequest(w, r, a.keys)
+	if !ok {
+		return
+	}
+
+	var dto followRemoteDto
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	user, err := a.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		a.respondWithUserLookupError(w, err)
+		return
+	}
+	if !user.Username.Valid {
+		utils.RespondWithError(w, http.StatusBadRequest, "Set a username before following remote accounts")
+		return
+	}
+
+	actor, err := a.federation.Follow(r.Context(), user.ID, dto.Account)
+	if err != nil {
+		if errors.Is(err, activitypub.ErrInvalidAccount) {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		a.logger.Printf("Remote follow error: %v\n", err)
+		utils.RespondWithError(w, http.StatusBadGateway, "Could not resolve account")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusAccepted, RemoteFollowResponse{ActorURI: actor.ID})
+}
+
+// Inbox receives activities from remote servers. Requests must carry an HTTP
+// signature made by the actor of the activity. Follows, Undo of a Follow,
+// Accepts of the caller's own Follows, and Create and Delete of Notes by
+// followed actors are acted upon; anything else is accepted and ignored.
+func (a *activityPubHandler) Inbox(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.getFederatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxActivitySize))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusRequestEntityTooLarge, "Activity is too large")
+		return
+	}
+	var activity activitypub.IncomingActivity
+	if err := json.Unmarshal(body, &activity); err != nil || activity.Actor == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid activity")
+		return
+	}
+
+	signer, err := a.federation.VerifyInboxRequest(r, body)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid signature")
+		return
+	}
+	if signer.ID != activity.Actor {
+		utils.RespondWithError(w, http.StatusForbidden, "Activity was not signed by its actor")
+		return
+	}
+
+	actorURL := a.federation.ActorURL(user.ID)
+	switch activity.Type {
+	case "Follow":
+		if activity.ObjectID() != actorURL {
+			utils.RespondWithError(w, http.StatusBadRequest, "Follow is not addressed to this actor")
+			return
+		}
+		err = a.federation.AcceptFollow(r.Context(), user.ID, activity, signer)
+	case "Undo":
+		var undone activitypub.IncomingActivity
+		if json.Unmarshal(activity.Object, &undone) == nil && undone.Type == "Follow" &&
+			undone.Actor == signer.ID && undone.ObjectID() == actorURL {
+			err = a.federation.RemoveFollower(r.Context(), user.ID, signer.ID)
+		}
+	case "Accept":
+		err = a.federation.ConfirmFollow(r.Context(), user.ID, activity, signer)
+	case "Create":
+		err = a.federation.StoreNote(r.Context(), user.ID, activity, signer)
+	case "Delete":
+		err = a.federation.DeleteNote(r.Context(), activity.ObjectID(), signer)
+	}
+	if errors.Is(err, activitypub.ErrNotAttributed) {
+		utils.RespondWithError(w, http.StatusForbidden, "Note was not written by its actor")
+		return
+	}
+	if err != nil {
+		a.logger.Printf("Inbox error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not process activity")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// getFederatedUser loads the user named by the userID path value. Users
+// without a username have no actor and are reported as not found.
+func (a *activityPubHandler) getFederatedUser(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	userID, ok := parseUserID(w, r)
+	if !ok {
+		return database.User{}, false
+	}
+	user, err := a.db.GetUserByID(r.Context(), userID)
+	if err == nil && !user.Username.Valid {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		a.respondWithUserLookupError(w, err)
+		return database.User{}, false
+	}
+	return user, true
+}
+
+func (a *activityPubHandler) respondWithUserLookupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusNotFound, "Account not found")
+		return
+	}
+	a.logger.Printf("DB error: %v\n", err)
+	utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve account")
+}
+
+// respondWithActivityJSON is utils.RespondWithJSON for the ActivityPub and
+// WebFinger media types.
+func respondWithActivityJSON(w http.ResponseWriter, code int, contentType string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(code)
+	w.Write(data)
+}
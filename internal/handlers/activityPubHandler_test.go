@@ -0,0 +1,467 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sheltonFr/bootdev/chirspy/internal/activitypub"
+	"github.com/sheltonFr/bootdev/chirspy/internal/auth"
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+)
+
+// federationDB answers the queries federation goes through from memory.
+type federationDB struct {
+	users         map[string]database.User
+	keys          map[string]database.ActorKey
+	chirps        []database.Chirp
+	followers     []database.RemoteFollower
+	remoteFollows []*database.RemoteFollow
+	notes         map[string]database.RemoteNote
+	deliveries    []*database.ApDelivery
+}
+
+func newFederationDB(users ...database.User) *federationDB {
+	return &federationDB{
+		users: usersByID(users...),
+		keys:  map[string]database.ActorKey{},
+		notes: map[string]database.RemoteNote{},
+	}
+}
+
+func (d *federationDB) register(fake *fakeDB) {
+	handleUsers(fake, d.users)
+	fake.handle("GetUserByUsername", func(args []driver.Value) ([][]any, error) {
+		for _, user := range d.users {
+			if strings.EqualFold(user.Username.String, args[0].(string)) {
+				return one(user), nil
+			}
+		}
+		return nil, nil
+	})
+	fake.handle("GetActorKey", func(args []driver.Value) ([][]any, error) {
+		if key, ok := d.keys[args[0].(string)]; ok {
+			return one(key), nil
+		}
+		return nil, nil
+	})
+	fake.handle("CreateActorKey", func(args []driver.Value) ([][]any, error) {
+		d.keys[args[0].(string)] = database.ActorKey{
+			UserID:        uuid.MustParse(args[0].(string)),
+			PrivateKeyPem: args[1].(string),
+			PublicKeyPem:  args[2].(string),
+			CreatedAt:     time.Now(),
+		}
+		return affected(1), nil
+	})
+	fake.handle("GetChirpyByID", func(args []driver.Value) ([][]any, error) {
+		for _, chirp := range d.chirps {
+			if chirp.ID.String() == args[0].(string) {
+				return one(chirp), nil
+			}
+		}
+		return nil, nil
+	})
+	fake.handle("CountOutboxChirps", func(args []driver.Value) ([][]any, error) {
+		return one(int64(len(d.chirps))), nil
+	})
+	fake.handle("ListOutboxChirps", func(args []driver.Value) ([][]any, error) {
+		var rows [][]any
+		for _, chirp := range d.chirps {
+			rows = append(rows, []any{chirp})
+		}
+		return rows, nil
+	})
+	fake.handle("UpsertRemoteFollower", func(args []driver.Value) ([][]any, error) {
+		d.removeFollower(args[0].(string), args[1].(string))
+		d.followers = append(d.followers, database.RemoteFollower{
+			UserID:         uuid.MustParse(args[0].(string)),
+			ActorUri:       args[1].(string),
+			InboxUrl:       args[2].(string),
+			SharedInboxUrl: args[3].(string),
+		})
+		return affected(1), nil
+	})
+	fake.handle("DeleteRemoteFollower", func(args []driver.Value) ([][]any, error) {
+		return affected(d.removeFollower(args[0].(string), args[1].(string))), nil
+	})
+	fake.handle("UpsertRemoteFollow", func(args []driver.Value) ([][]any, error) {
+		d.remoteFollows = append(d.remoteFollows, &database.RemoteFollow{
+			UserID:   uuid.MustParse(args[0].(string)),
+			ActorUri: args[1].(string),
+			FollowID: args[2].(string),
+		})
+		return affected(1), nil
+	})
+	fake.handle("AcceptRemoteFollow", func(args []driver.Value) ([][]any, error) {
+		for _, follow := range d.remoteFollows {
+			if follow.UserID.String() == args[0].(string) && follow.ActorUri == args[1].(string) && follow.FollowID == args[2].(string) {
+				follow.AcceptedAt = sql.NullTime{Time: time.Now(), Valid: true}
+				return affected(1), nil
+			}
+		}
+		return nil, nil
+	})
+	fake.handle("IsFollowingRemoteActor", func(args []driver.Value) ([][]any, error) {
+		for _, follow := range d.remoteFollows {
+			if follow.UserID.String() == args[0].(string) && follow.ActorUri == args[1].(string) {
+				return one(follow.AcceptedAt.Valid), nil
+			}
+		}
+		return one(false), nil
+	})
+	fake.handle("UpsertRemoteNote", func(args []driver.Value) ([][]any, error) {
+		d.notes[args[0].(string)] = database.RemoteNote{
+			ID:          args[0].(string),
+			ActorUri:    args[1].(string),
+			Content:     args[2].(string),
+			Url:         args[3].(string),
+			InReplyTo:   args[4].(string),
+			PublishedAt: args[5].(time.Time),
+		}
+		return affected(1), nil
+	})
+	fake.handle("DeleteRemoteNote", func(args []driver.Value) ([][]any, error) {
+		if note, ok := d.notes[args[0].(string)]; ok && note.ActorUri == args[1].(string) {
+			delete(d.notes, note.ID)
+			return affected(1), nil
+		}
+		return nil, nil
+	})
+	fake.handle("EnqueueDelivery", func(args []driver.Value) ([][]any, error) {
+		d.enqueue(args[0].(string), args[1].(string), args[2].(string))
+		return affected(1), nil
+	})
+	fake.handle("EnqueueDeliveryToFollowers", func(args []driver.Value) ([][]any, error) {
+		queued := 0
+		for _, follower := range d.followers {
+			if follower.UserID.String() == args[1].(string) {
+				d.enqueue(args[1].(string), follower.InboxUrl, args[0].(string))
+				queued++
+			}
+		}
+		return affected(queued), nil
+	})
+	fake.handle("ClaimNextDelivery", func(args []driver.Value) ([][]any, error) {
+		now := time.Now()
+		for _, delivery := range d.deliveries {
+			pending := !delivery.DeliveredAt.Valid && !delivery.FailedAt.Valid
+			if pending && !delivery.NextAttemptAt.After(now) {
+				delivery.NextAttemptAt = now.Add(time.Duration(args[0].(float64) * float64(time.Second)))
+				return one(*delivery), nil
+			}
+		}
+		return nil, nil
+	})
+	fake.handle("MarkDeliveryDelivered", func(args []driver.Value) ([][]any, error) {
+		delivery := d.delivery(args[0].(int64))
+		delivery.Attempts++
+		delivery.DeliveredAt = sql.NullTime{Time: time.Now(), Valid: true}
+		return affected(1), nil
+	})
+	fake.handle("RetryDelivery", func(args []driver.Value) ([][]any, error) {
+		delivery := d.delivery(args[0].(int64))
+		delivery.Attempts++
+		delivery.NextAttemptAt = args[1].(time.Time)
+		delivery.LastError = args[2].(string)
+		return affected(1), nil
+	})
+	fake.handle("FailDelivery", func(args []driver.Value) ([][]any, error) {
+		delivery := d.delivery(args[0].(int64))
+		delivery.Attempts++
+		delivery.FailedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		delivery.LastError = args[1].(string)
+		return affected(1), nil
+	})
+}
+
+func (d *federationDB) removeFollower(userID, actorURI string) int {
+	for i, follower := range d.followers {
+		if follower.UserID.String() == userID && follower.ActorUri == actorURI {
+			d.followers = append(d.followers[:i], d.followers[i+1:]...)
+			return 1
+		}
+	}
+	return 0
+}
+
+func (d *federationDB) enqueue(senderID, inboxURL, payload string) {
+	d.deliveries = append(d.deliveries, &database.ApDelivery{
+		ID:            int64(len(d.deliveries) + 1),
+		SenderID:      uuid.MustParse(senderID),
+		InboxUrl:      inboxURL,
+		Payload:       payload,
+		NextAttemptAt: time.Now(),
+		CreatedAt:     time.Now(),
+	})
+}
+
+func (d *federationDB) delivery(id int64) *database.ApDelivery {
+	return d.deliveries[id-1]
+}
+
+// instance is a Chirpy server of its own, with the ActivityPub routes of
+// main.go served over a local port. While down is set it answers every
+// request with 503.
+type instance struct {
+	*httptest.Server
+	state      *federationDB
+	db         *database.Queries
+	federation *activitypub.Federation
+	down       atomic.Bool
+}
+
+func newInstance(t *testing.T, keys *auth.KeySet, users ...database.User) *instance {
+	t.Helper()
+	logger := log.New(io.Discard, "", 0)
+	fake, conn := newFakeDB(t)
+	i := &instance{state: newFederationDB(users...), db: database.New(conn)}
+	i.state.register(fake)
+
+	mux := http.NewServeMux()
+	i.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if i.down.Load() {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(i.Close)
+
+	federation, err := activitypub.NewFederation(i.db, logger, i.URL)
+	if err != nil {
+		t.Fatalf("Failed to set up federation: %v", err)
+	}
+	federation.AllowPrivateNetworks()
+	i.federation = federation
+
+	handler := NewActivityPubHandler(i.db, logger, keys, federation)
+	mux.HandleFunc("GET /.well-known/webfinger", handler.WebFinger)
+	mux.HandleFunc("GET /ap/users/{userID}", handler.GetActor)
+	mux.HandleFunc("GET /ap/users/{userID}/outbox", handler.GetOutbox)
+	mux.HandleFunc("POST /ap/users/{userID}/inbox", handler.Inbox)
+	mux.HandleFunc("GET /ap/chirps/{chirpID}", handler.GetNote)
+	mux.HandleFunc("POST /api/users/me/remote-follows", handler.FollowRemote)
+	return i
+}
+
+func (i *instance) deliverDue(t *testing.T) {
+	t.Helper()
+	if err := i.federation.DeliverDue(t.Context()); err != nil {
+		t.Fatalf("Delivery run failed: %v", err)
+	}
+}
+
+// post signs an activity as a user of the instance and posts it to inboxURL.
+func (i *instance) post(t *testing.T, userID uuid.UUID, inboxURL string, activity any) int {
+	t.Helper()
+	body, _ := json.Marshal(activity)
+	key, err := activitypub.ParsePrivateKey(i.state.keys[userID.String()].PrivateKeyPem)
+	if err != nil {
+		t.Fatalf("Failed to parse private key: %v", err)
+	}
+	req, _ := http.NewRequest(http.MethodPost, inboxURL, bytes.NewReader(body))
+	req.Header.Set("Content-Type", activitypub.ContentType)
+	if err := activitypub.SignRequest(req, i.federation.KeyID(userID), key, body); err != nil {
+		t.Fatalf("Failed to sign request: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to post activity: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func getActivityJSON[T any](t *testing.T, rawURL string) T {
+	t.Helper()
+	resp, err := http.Get(rawURL)
+	if err != nil {
+		t.Fatalf("Failed to get %s: %v", rawURL, err)
+	}
+	defer resp.Body.Close()
+	var v T
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		t.Fatalf("Failed to decode %s: %v", rawURL, err)
+	}
+	return v
+}
+
+// TestFederation runs two Chirpy instances: bob, on the first, follows
+// alice, on the second, and receives her Notes once the delivery workers
+// have run.
+func TestFederation(t *testing.T) {
+	keys := newTestKeys(t)
+	bob := newTestUser("bob")
+	bob.Username = sql.NullString{String: "bob", Valid: true}
+	alice := newTestUser("alice")
+	alice.Username = sql.NullString{String: "alice", Valid: true}
+
+	home := newInstance(t, keys, bob)
+	remote := newInstance(t, keys, alice)
+
+	bobURL := home.federation.ActorURL(bob.ID)
+	aliceURL := remote.federation.ActorURL(alice.ID)
+	chirp := database.Chirp{
+		ID:        uuid.New(),
+		Body:      "Hello fediverse",
+		UserID:    alice.ID,
+		CreatedAt: time.Now().Truncate(time.Second),
+		UpdatedAt: time.Now(),
+		Kind:      chirpKindChirp,
+	}
+	noteURL := remote.federation.NoteURL(chirp.ID)
+	remote.state.chirps = append(remote.state.chirps, chirp)
+
+	t.Run("Follow through WebFinger is accepted", func(t *testing.T) {
+		host, _ := url.Parse(remote.URL)
+		rec := apiCall(t, home.Config.Handler, keys, bob.ID, "POST", "/api/users/me/remote-follows", followRemoteDto{Account: "alice@" + host.Host})
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("Expected 202, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if actor := decodeResponse[RemoteFollowResponse](t, rec).ActorURI; actor != aliceURL {
+			t.Fatalf("Expected alice's actor %s, got %s", aliceURL, actor)
+		}
+
+		home.deliverDue(t)
+		if len(remote.state.followers) != 1 || remote.state.followers[0].ActorUri != bobURL {
+			t.Fatalf("Expected bob to follow alice, got %v", remote.state.followers)
+		}
+		if home.state.remoteFollows[0].AcceptedAt.Valid {
+			t.Fatalf("Expected the follow to wait for an Accept")
+		}
+
+		remote.deliverDue(t)
+		if !home.state.remoteFollows[0].AcceptedAt.Valid {
+			t.Fatalf("Expected alice's server to accept the follow")
+		}
+	})
+
+	t.Run("Unknown account", func(t *testing.T) {
+		host, _ := url.Parse(remote.URL)
+		rec := apiCall(t, home.Config.Handler, keys, bob.ID, "POST", "/api/users/me/remote-follows", followRemoteDto{Account: "carol@" + host.Host})
+		if rec.Code != http.StatusBadGateway {
+			t.Fatalf("Expected 502, got %d", rec.Code)
+		}
+		rec = apiCall(t, home.Config.Handler, keys, bob.ID, "POST", "/api/users/me/remote-follows", followRemoteDto{Account: "carol"})
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("Expected 400, got %d", rec.Code)
+		}
+	})
+
+	t.Run("Activity not signed by its actor", func(t *testing.T) {
+		forged := activitypub.Activity{
+			ID:     "https://elsewhere.example/users/mallory#follows/1",
+			Type:   "Follow",
+			Actor:  "https://elsewhere.example/users/mallory",
+			Object: aliceURL,
+		}
+		if code := home.post(t, bob.ID, aliceURL+"/inbox", forged); code != http.StatusForbidden {
+			t.Fatalf("Expected 403, got %d", code)
+		}
+	})
+
+	t.Run("Create is retried until delivered", func(t *testing.T) {
+		home.down.Store(true)
+		if err := remote.federation.EnqueueCreate(t.Context(), remote.db, chirp); err != nil {
+			t.Fatalf("Failed to queue Create: %v", err)
+		}
+		remote.deliverDue(t)
+		delivery := remote.state.deliveries[len(remote.state.deliveries)-1]
+		if delivery.Attempts != 1 || !delivery.NextAttemptAt.After(time.Now()) || delivery.FailedAt.Valid {
+			t.Fatalf("Expected the delivery to be rescheduled, got %+v", delivery)
+		}
+
+		home.down.Store(false)
+		delivery.NextAttemptAt = time.Now()
+		remote.deliverDue(t)
+		if delivery.Attempts != 2 || !delivery.DeliveredAt.Valid {
+			t.Fatalf("Expected the delivery to succeed on the second attempt, got %+v", delivery)
+		}
+		note, ok := home.state.notes[noteURL]
+		if !ok || note.ActorUri != aliceURL || note.Content != "<p>Hello fediverse</p>" || !note.PublishedAt.Equal(chirp.CreatedAt) {
+			t.Fatalf("Expected bob's server to keep alice's Note, got %+v", home.state.notes)
+		}
+	})
+
+	t.Run("Stored Note matches the published one", func(t *testing.T) {
+		published := getActivityJSON[activitypub.Note](t, noteURL)
+		if stored := home.state.notes[noteURL]; published.Content != stored.Content || published.URL != stored.Url {
+			t.Fatalf("Expected %+v to match %+v", stored, published)
+		}
+		outbox := getActivityJSON[struct {
+			TotalItems   int64                          `json:"totalItems"`
+			OrderedItems []activitypub.IncomingActivity `json:"orderedItems"`
+		}](t, aliceURL+"/outbox")
+		if outbox.TotalItems != 1 || outbox.OrderedItems[0].ObjectID() != noteURL {
+			t.Fatalf("Expected the outbox to list the Note, got %+v", outbox)
+		}
+	})
+
+	t.Run("Note attributed to someone else", func(t *testing.T) {
+		note := remote.federation.Note(chirp)
+		note.AttributedTo = bobURL
+		create := activitypub.Activity{ID: note.ID + "/activity", Type: "Create", Actor: aliceURL, Object: note}
+		if code := remote.post(t, alice.ID, bobURL+"/inbox", create); code != http.StatusForbidden {
+			t.Fatalf("Expected 403, got %d", code)
+		}
+	})
+
+	t.Run("Delete removes the Note", func(t *testing.T) {
+		if err := remote.federation.EnqueueDelete(t.Context(), remote.db, chirp); err != nil {
+			t.Fatalf("Failed to queue Delete: %v", err)
+		}
+		remote.deliverDue(t)
+		if _, ok := home.state.notes[noteURL]; ok {
+			t.Fatalf("Expected bob's server to drop the Note")
+		}
+	})
+
+	t.Run("Delivery to an unknown account is given up", func(t *testing.T) {
+		delete(home.state.users, bob.ID.String())
+		defer func() { home.state.users[bob.ID.String()] = bob }()
+
+		if err := remote.federation.EnqueueCreate(t.Context(), remote.db, chirp); err != nil {
+			t.Fatalf("Failed to queue Create: %v", err)
+		}
+		remote.deliverDue(t)
+		delivery := remote.state.deliveries[len(remote.state.deliveries)-1]
+		if delivery.Attempts != 1 || !delivery.FailedAt.Valid {
+			t.Fatalf("Expected the delivery to be given up, got %+v", delivery)
+		}
+	})
+
+	t.Run("Undo removes the follower", func(t *testing.T) {
+		undo := activitypub.Activity{
+			ID:     bobURL + "#undos/1",
+			Type:   "Undo",
+			Actor:  bobURL,
+			Object: activitypub.Activity{ID: home.state.remoteFollows[0].FollowID, Type: "Follow", Actor: bobURL, Object: aliceURL},
+		}
+		if code := home.post(t, bob.ID, aliceURL+"/inbox", undo); code != http.StatusAccepted {
+			t.Fatalf("Expected 202, got %d", code)
+		}
+		if len(remote.state.followers) != 0 {
+			t.Fatalf("Expected bob to no longer follow alice, got %v", remote.state.followers)
+		}
+
+		queued := len(remote.state.deliveries)
+		if err := remote.federation.EnqueueCreate(t.Context(), remote.db, chirp); err != nil {
+			t.Fatalf("Failed to queue Create: %v", err)
+		}
+		if len(remote.state.deliveries) != queued {
+			t.Fatalf("Expected nothing to be queued without followers")
+		}
+	})
+}
@@ -17,13 +17,14 @@ import (
 )
 
 type authHandler struct {
-	db        *database.Queries
-	logger    *log.Logger
-	jwtSecret string
+	db     *database.Queries
+	conn   *sql.DB
+	logger *log.Logger
+	keys   *auth.KeySet
 }
 
-func NewAuthHandler(db *database.Queries, logger *log.Logger, jwtSecret string) *authHandler {
-	return &authHandler{db, logger, jwtSecret}
+func NewAuthHandler(db *database.Queries, conn *sql.DB, logger *log.Logger, keys *auth.KeySet) *authHandler {
+	return &authHandler{db, conn, logger, keys}
 }
 
 type LoginDTO struct {
@@ -32,9 +33,17 @@ type LoginDTO struct {
 }
 
 type RefreshJWTResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
 }
 
+const refreshTokenLifetimeDays = 60
+
+var (
+	errRefreshTokenReused  = errors.New("Refresh token has already been used")
+	errRefreshTokenRevoked = errors.New("Refresh token has been revoked")
+)
+
 func (a *authHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var loginDTO LoginDTO
 	decoder := json.NewDecoder(r.Body)
@@ -62,48 +71,96 @@ func (a *authHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	expiry := time.Hour
-	token, err := auth.MakeJWT(user.ID, a.jwtSecret, expiry)
+	token, err := a.keys.MakeJWT(user.ID, expiry)
 	if err != nil {
-		a.logger.Fatalf("Jwt error: %v", err)
+		a.logger.Printf("Jwt error: %v\n", err)
 		utils.RespondWithError(w, http.StatusInternalServerError, "An error occured")
 		return
 	}
 	refreshToken, _ := auth.MakeRefreshToken()
 	_, err = a.db.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
-		Token:     refreshToken,
+		Token:     auth.HashRefreshToken(refreshToken),
 		UserID:    user.ID,
-		ExpiresAt: time.Now().AddDate(0, 0, 60),
+		ExpiresAt: time.Now().AddDate(0, 0, refreshTokenLifetimeDays),
+		FamilyID:  uuid.New(),
+		UserAgent: userAgent(r),
+		IpAddress: clientIP(r),
 	})
 	if err != nil {
-		a.logger.Fatalf("Refresh Token  error: %v", err)
+		a.logger.Printf("DB error: %v\n", err)
 		utils.RespondWithError(w, http.StatusInternalServerError, "An error occured")
 		return
 	}
 	utils.RespondWithJSON(w, http.StatusOK, mappers.MapUserLogin(&user, token, refreshToken))
 }
 
+// RefreshTokenHandler exchanges a refresh token for a new access token and a
+// new refresh token of the same family. The presented token is revoked, and
+// presenting a revoked token again revokes its whole family.
 func (a *authHandler) RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Access toke is required")
 		return
 	}
-	userID, err := a.validateRefreshToken(r.Context(), token)
+	oldToken, err := a.validateRefreshToken(r.Context(), token)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusUnauthorized, err.Error())
 		return
 	}
 
+	newToken, _ := auth.MakeRefreshToken()
+	newTokenHash := auth.HashRefreshToken(newToken)
+	// Revoking the old token and storing its successor commit together, so a
+	// failed insert leaves the old token usable for the client's retry.
+	err = runInTx(r.Context(), a.conn, a.db, func(q *database.Queries) error {
+		rows, err := q.RotateRefreshToken(r.Context(), database.RotateRefreshTokenParams{
+			ReplacedBy: sql.NullString{String: newTokenHash, Valid: true},
+			Token:      oldToken.Token,
+		})
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return errRefreshTokenRevoked
+		}
+		_, err = q.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+			Token:     newTokenHash,
+			UserID:    oldToken.UserID,
+			ExpiresAt: time.Now().AddDate(0, 0, refreshTokenLifetimeDays),
+			FamilyID:  oldToken.FamilyID,
+			UserAgent: userAgent(r),
+			IpAddress: clientIP(r),
+		})
+		return err
+	})
+	if errors.Is(err, errRefreshTokenRevoked) {
+		// Another request rotated or revoked this token first.
+		refreshToken, err := a.db.GetRefreshToken(r.Context(), oldToken.Token)
+		if err != nil {
+			a.logger.Printf("DB error: %v\n", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "An error occured")
+			return
+		}
+		utils.RespondWithError(w, http.StatusUnauthorized, a.rejectRevokedToken(r.Context(), refreshToken).Error())
+		return
+	}
+	if err != nil {
+		a.logger.Printf("Refresh Token  error: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "An error occured")
+		return
+	}
+
 	expiry := time.Hour
-	accessToken, err := auth.MakeJWT(userID, a.jwtSecret, expiry)
+	accessToken, err := a.keys.MakeJWT(oldToken.UserID, expiry)
 
 	if err != nil {
-		a.logger.Fatalf("Jwt Token  error: %v", err)
+		a.logger.Printf("Jwt Token  error: %v", err)
 		utils.RespondWithError(w, http.StatusInternalServerError, "An error occured")
 		return
 	}
 
-	utils.RespondWithJSON(w, http.StatusOK, RefreshJWTResponse{accessToken})
+	utils.RespondWithJSON(w, http.StatusOK, RefreshJWTResponse{accessToken, newToken})
 }
 
 func (a *authHandler) RevokeRefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
@@ -112,22 +169,49 @@ func (a *authHandler) RevokeRefreshTokenHandler(w http.ResponseWriter, r *http.R
 		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	a.db.RevokeRefreshToken(r.Context(), token)
+	a.db.RevokeRefreshToken(r.Context(), auth.HashRefreshToken(token))
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (a *authHandler) validateRefreshToken(ctx context.Context, token string) (uuid.UUID, error) {
-	refreshToken, err := a.db.GetRefreshToken(ctx, token)
+// validateRefreshToken returns the stored token if it can still be used. A
+// token that was already rotated being presented means it leaked, so its
+// family is revoked.
+func (a *authHandler) validateRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	refreshToken, err := a.db.GetRefreshToken(ctx, auth.HashRefreshToken(token))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return uuid.Nil, errors.New("Token not found")
+			return database.RefreshToken{}, errors.New("Token not found")
 		}
-		return uuid.Nil, errors.New("Unexpected error")
+		return database.RefreshToken{}, errors.New("Unexpected error")
+	}
+
+	if refreshToken.RevokedAt.Valid {
+		return database.RefreshToken{}, a.rejectRevokedToken(ctx, refreshToken)
+	}
+
+	if refreshToken.ExpiresAt.Before(time.Now()) {
+		return database.RefreshToken{}, errors.New("Refresh token is no longer valid")
 	}
 
-	if refreshToken.ExpiresAt.Before(time.Now()) || refreshToken.RevokedAt.Valid {
-		return uuid.Nil, errors.New("Refresh token is no longer valid")
+	return refreshToken, nil
+}
+
+// rejectRevokedToken returns the error for a revoked token being presented.
+// Only a token that was replaced by a successor counts as reuse; one revoked
+// by a logout or a session revocation is simply no longer valid.
+func (a *authHandler) rejectRevokedToken(ctx context.Context, refreshToken database.RefreshToken) error {
+	if !refreshToken.ReplacedBy.Valid {
+		return errRefreshTokenRevoked
 	}
+	a.revokeFamily(ctx, refreshToken)
+	return errRefreshTokenReused
+}
 
-	return refreshToken.UserID, nil
+func (a *authHandler) revokeFamily(ctx context.Context, refreshToken database.RefreshToken) {
+	a.logger.Printf("SECURITY: refresh token reuse detected for user %s, revoking token family %s\n",
+		refreshToken.UserID, refreshToken.FamilyID)
+	err := a.db.RevokeRefreshTokenFamily(ctx, refreshToken.FamilyID)
+	if err != nil {
+		a.logger.Printf("DB error: could not revoke token family %s: %v\n", refreshToken.FamilyID, err)
+	}
 }
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sheltonFr/bootdev/chirspy/internal/auth"
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+	"github.com/sheltonFr/bootdev/chirspy/internal/mappers"
+	"github.com/sheltonFr/bootdev/chirspy/internal/pagination"
+	"github.com/sheltonFr/bootdev/chirspy/internal/utils"
+)
+
+// blockHandler manages the users a caller blocks or mutes. Both hide the
+// other user's chirps from the caller; a block also stops them from
+// following, replying to, mentioning or messaging the caller.
+type blockHandler struct {
+	db     *database.Queries
+	conn   *sql.DB
+	logger *log.Logger
+	keys   *auth.KeySet
+}
+
+func NewBlockHandler(db *database.Queries, conn *sql.DB, logger *log.Logger, keys *auth.KeySet) *blockHandler {
+	return &blockHandler{db, conn, logger, keys}
+}
+
+// UserRelationResponse is one entry of the caller's block or mute list.
+type UserRelationResponse struct {
+	User      mappers.PublicUserResponse `json:"user"`
+	CreatedAt time.Time                  `json:"created_at"`
+}
+
+// Block blocks the user in the path and removes any follow between the two
+// users. Blocking someone twice is not an error.
+func (b *blockHandler) Block(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := b.parseRelation(w, r, "You cannot block yourself")
+	if !ok {
+		return
+	}
+
+	err := runInTx(r.Context(), b.conn, b.db, func(q *database.Queries) error {
+		if _, err := q.BlockUser(r.Context(), database.BlockUserParams{
+			BlockerID: userID,
+			BlockedID: targetID,
+		}); err != nil {
+			return err
+		}
+		return q.DeleteFollowsBetween(r.Context(), database.DeleteFollowsBetweenParams{
+			UserA: userID,
+			UserB: targetID,
+		})
+	})
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			utils.RespondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		b.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not block user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (b *blockHandler) Unblock(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticateRequest(w, r, b.keys)
+	if !ok {
+		return
+	}
+	targetID, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := b.db.UnblockUser(r.Context(), database.UnblockUserParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	})
+	if err != nil {
+		b.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not unblock user")
+		return
+	}
+	if rows == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "You have not blocked this user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Mute hides the chirps of the user in the path from the caller only. Muting
+// someone twice is not an error.
+func (b *blockHandler) Mute(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := b.parseRelation(w, r, "You cannot mute yourself")
+	if !ok {
+		return
+	}
+
+	_, err := b.db.MuteUser(r.Context(), database.MuteUserParams{
+		MuterID: userID,
+		MutedID: targetID,
+	})
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			utils.RespondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		b.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not mute user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (b *blockHandler) Unmute(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticateRequest(w, r, b.keys)
+	if !ok {
+		return
+	}
+	targetID, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := b.db.UnmuteUser(r.Context(), database.UnmuteUserParams{
+		MuterID: userID,
+		MutedID: targetID,
+	})
+	if err != nil {
+		b.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not unmute user")
+		return
+	}
+	if rows == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "You have not muted this user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListBlocks lists the users the caller blocked, most recent first.
+func (b *blockHandler) ListBlocks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticateRequest(w, r, b.keys)
+	if !ok {
+		return
+	}
+	page, ok := parseDescPage(w, r, "Blocks can only be sorted desc")
+	if !ok {
+		return
+	}
+
+	cursorCreatedAt, cursorID := page.CursorArgs()
+	rows, err := b.db.ListBlockedUsers(r.Context(), database.ListBlockedUsersParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		Limit:           page.Limit + 1,
+	})
+	if err != nil {
+		b.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch blocked users")
+		return
+	}
+
+	relations := make([]UserRelationResponse, len(rows))
+	for i, row := range rows {
+		relations[i] = UserRelationResponse{mappers.MapPublicUser(&row.User), row.BlockedAt}
+	}
+	respondWithRelationPage(w, r, relations, page)
+}
+
+// ListMutes lists the users the caller muted, most recent first.
+func (b *blockHandler) ListMutes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticateRequest(w, r, b.keys)
+	if !ok {
+		return
+	}
+	page, ok := parseDescPage(w, r, "Mutes can only be sorted desc")
+	if !ok {
+		return
+	}
+
+	cursorCreatedAt, cursorID := page.CursorArgs()
+	rows, err := b.db.ListMutedUsers(r.Context(), database.ListMutedUsersParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		Limit:           page.Limit + 1,
+	})
+	if err != nil {
+		b.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch muted users")
+		return
+	}
+
+	relations := make([]UserRelationResponse, len(rows))
+	for i, row := range rows {
+		relations[i] = UserRelationResponse{mappers.MapPublicUser(&row.User), row.MutedAt}
+	}
+	respondWithRelationPage(w, r, relations, page)
+}
+
+// parseRelation authenticates the caller and reads the target user from the
+// path, rejecting a target that is the caller with selfMsg.
+func (b *blockHandler) parseRelation(w http.ResponseWriter, r *http.Request, selfMsg string) (uuid.UUID, uuid.UUID, bool) {
+	userID, ok := authenticateRequest(w, r, b.keys)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+	targetID, ok := parseUserID(w, r)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+	if userID == targetID {
+		utils.RespondWithError(w, http.StatusBadRequest, selfMsg)
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, targetID, true
+}
+
+func respondWithRelationPage(w http.ResponseWriter, r *http.Request, relations []UserRelationResponse, page pagination.Params) {
+	relations, hasMore := pagination.Trim(relations, page)
+	if hasMore {
+		last := relations[len(relations)-1]
+		w.Header().Set("Link", pagination.NextLink(r.URL, pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.User.ID}))
+	}
+	utils.RespondWithJSON(w, http.StatusOK, relations)
+}
@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sheltonFr/bootdev/chirspy/internal/activitypub"
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+	"github.com/sheltonFr/bootdev/chirspy/internal/notifications"
+	"github.com/sheltonFr/bootdev/chirspy/internal/stream"
+	"github.com/sheltonFr/bootdev/chirspy/internal/webhooks"
+)
+
+// socialDB keeps the blocks, mutes and follows written through the handlers
+// in memory. Listings are answered with canned rows: which chirps a viewer
+// may see is decided by the SQL, so tests only check the viewer each query
+// is given, which it records in args.
+type socialDB struct {
+	users         map[string]database.User
+	blocks        map[[2]string]bool
+	mutes         map[[2]string]bool
+	follows       map[[2]string]bool
+	chirps        []database.Chirp
+	mentioned     []uuid.UUID
+	notifications []database.Notification
+	args          map[string][]driver.Value
+}
+
+func newSocialDB(users ...database.User) *socialDB {
+	return &socialDB{
+		users:   usersByID(users...),
+		blocks:  map[[2]string]bool{},
+		mutes:   map[[2]string]bool{},
+		follows: map[[2]string]bool{},
+		args:    map[string][]driver.Value{},
+	}
+}
+
+func (d *socialDB) addChirp(author database.User, body string, inReplyTo uuid.NullUUID) database.Chirp {
+	chirp := database.Chirp{
+		ID:        uuid.New(),
+		Body:      body,
+		UserID:    author.ID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		InReplyTo: inReplyTo,
+		Kind:      chirpKindChirp,
+	}
+	d.chirps = append(d.chirps, chirp)
+	return chirp
+}
+
+// setRelation answers BlockUser and MuteUser, which fail on unknown users
+// like the foreign keys do.
+func (d *socialDB) setRelation(relations map[[2]string]bool) fakeQuery {
+	return func(args []driver.Value) ([][]any, error) {
+		if _, ok := d.users[args[1].(string)]; !ok {
+			return nil, &pq.Error{Code: pqForeignKeyViolation}
+		}
+		key := [2]string{args[0].(string), args[1].(string)}
+		if relations[key] {
+			return nil, nil
+		}
+		relations[key] = true
+		return affected(1), nil
+	}
+}
+
+func (d *socialDB) deleteRelation(relations map[[2]string]bool) fakeQuery {
+	return func(args []driver.Value) ([][]any, error) {
+		key := [2]string{args[0].(string), args[1].(string)}
+		if !relations[key] {
+			return nil, nil
+		}
+		delete(relations, key)
+		return affected(1), nil
+	}
+}
+
+// canned answers the query name with rows, whatever its arguments, and
+// records them.
+func (d *socialDB) canned(fake *fakeDB, name string, rows func() [][]any) {
+	fake.handle(name, func(args []driver.Value) ([][]any, error) {
+		d.args[name] = args
+		return rows(), nil
+	})
+}
+
+func (d *socialDB) register(fake *fakeDB) {
+	handleUsers(fake, d.users)
+	handleBlocks(fake, d.blocks)
+	fake.handle("BlockUser", d.setRelation(d.blocks))
+	fake.handle("UnblockUser", d.deleteRelation(d.blocks))
+	fake.handle("MuteUser", d.setRelation(d.mutes))
+	fake.handle("UnmuteUser", d.deleteRelation(d.mutes))
+	fake.handle("DeleteFollowsBetween", func(args []driver.Value) ([][]any, error) {
+		a, b := args[0].(string), args[1].(string)
+		delete(d.follows, [2]string{a, b})
+		delete(d.follows, [2]string{b, a})
+		return nil, nil
+	})
+	fake.handle("CreateFollow", func(args []driver.Value) ([][]any, error) {
+		d.follows[[2]string{args[0].(string), args[1].(string)}] = true
+		return affected(1), nil
+	})
+	fake.handle("CreateNotification", func(args []driver.Value) ([][]any, error) {
+		notification := database.Notification{
+			ID:        int64(len(d.notifications) + 1),
+			UserID:    uuid.MustParse(args[0].(string)),
+			Kind:      args[1].(string),
+			ActorID:   uuid.MustParse(args[2].(string)),
+			CreatedAt: time.Now(),
+		}
+		d.notifications = append(d.notifications, notification)
+		return one(notification), nil
+	})
+	fake.handle("GetChirpyByID", func(args []driver.Value) ([][]any, error) {
+		for _, chirp := range d.chirps {
+			if chirp.ID.String() == args[0].(string) {
+				return one(chirp), nil
+			}
+		}
+		return nil, nil
+	})
+	fake.handle("CreateChirpy", func(args []driver.Value) ([][]any, error) {
+		return one(d.addChirp(d.users[args[1].(string)], args[0].(string), uuid.NullUUID{})), nil
+	})
+
+	chirps := func() [][]any {
+		var rows [][]any
+		for _, chirp := range d.chirps {
+			rows = append(rows, []any{chirp})
+		}
+		return rows
+	}
+	for _, name := range []string{"ListChirpsAsc", "ListChirpsDesc", "ListTimelineAsc", "ListTimelineDesc"} {
+		d.canned(fake, name, chirps)
+	}
+	d.canned(fake, "ListReplyTree", func() [][]any {
+		var rows [][]any
+		for _, chirp := range d.chirps {
+			if chirp.InReplyTo.Valid {
+				rows = append(rows, []any{chirp, int32(1)})
+			}
+		}
+		return rows
+	})
+	d.canned(fake, "CreateMentions", func() [][]any {
+		var rows [][]any
+		for _, id := range d.mentioned {
+			rows = append(rows, []any{id})
+		}
+		return rows
+	})
+	handleEmpty(fake,
+		"GetChirpAncestors", "DeleteChirpTags", "DeleteMentionsNotIn",
+		"EnqueueDeliveryToFollowers", "EnqueueWebhookDeliveries",
+		"GetReactionSummaries", "GetChirpMedia", "GetChirpMentions",
+	)
+}
+
+// TestBlocksAndMutes checks that blocks stop follows and replies both ways,
+// and that listings, threads and mentions hand the caller to the SQL that
+// applies blocks and mutes.
+func TestBlocksAndMutes(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	keys := newTestKeys(t)
+
+	alice := newTestUser("alice")
+	alice.Username = sql.NullString{String: "alice", Valid: true}
+	bob := newTestUser("bob")
+	bob.Username = sql.NullString{String: "bob", Valid: true}
+	carol := newTestUser("carol")
+	carol.Username = sql.NullString{String: "carol", Valid: true}
+
+	setup := func(t *testing.T) (*socialDB, http.Handler) {
+		t.Helper()
+		state := newSocialDB(alice, bob, carol)
+		fake, conn := newFakeDB(t)
+		state.register(fake)
+		db := database.New(conn)
+
+		federation, err := activitypub.NewFederation(db, logger, "http://localhost:8080")
+		if err != nil {
+			t.Fatalf("Failed to set up federation: %v", err)
+		}
+		notifier := notifications.NewNotifier(db, notifications.NewBroker(16), logger)
+		blocks := NewBlockHandler(db, conn, logger, keys)
+		follows := NewFollowHandler(db, logger, keys, notifier)
+		chirps := NewChirpyHandler(db, conn, logger, keys, nil, stream.NewHub(16, 16), notifier, federation, webhooks.NewDispatcher(db, logger))
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST /api/users/{userID}/follow", follows.Follow)
+		mux.HandleFunc("POST /api/users/{userID}/block", blocks.Block)
+		mux.HandleFunc("DELETE /api/users/{userID}/block", blocks.Unblock)
+		mux.HandleFunc("POST /api/users/{userID}/mute", blocks.Mute)
+		mux.HandleFunc("DELETE /api/users/{userID}/mute", blocks.Unmute)
+		mux.HandleFunc("POST /api/chirps", chirps.CreateChirpy)
+		mux.HandleFunc("GET /api/chirps", chirps.GetAllChirps)
+		mux.HandleFunc("GET /api/timeline", chirps.GetTimeline)
+		mux.HandleFunc("GET /api/chirps/{chirpID}/thread", chirps.GetThread)
+		return state, mux
+	}
+
+	expectStatus := func(t *testing.T, rec *httptest.ResponseRecorder, expected int) {
+		t.Helper()
+		if rec.Code != expected {
+			t.Fatalf("Expected status %d, got %d: %s", expected, rec.Code, rec.Body.String())
+		}
+	}
+
+	authors := func(chirps []ChirpResponse) []uuid.UUID {
+		ids := make([]uuid.UUID, len(chirps))
+		for i, chirp := range chirps {
+			ids[i] = chirp.UserID
+		}
+		return ids
+	}
+
+	t.Run("Blocking or muting yourself", func(t *testing.T) {
+		_, handler := setup(t)
+		expectStatus(t, apiCall(t, handler, keys, alice.ID, "POST", "/api/users/"+alice.ID.String()+"/block", nil), http.StatusBadRequest)
+		expectStatus(t, apiCall(t, handler, keys, alice.ID, "POST", "/api/users/"+alice.ID.String()+"/mute", nil), http.StatusBadRequest)
+	})
+
+	t.Run("Blocking or muting an unknown user", func(t *testing.T) {
+		_, handler := setup(t)
+		expectStatus(t, apiCall(t, handler, keys, alice.ID, "POST", "/api/users/"+uuid.NewString()+"/block", nil), http.StatusNotFound)
+		expectStatus(t, apiCall(t, handler, keys, alice.ID, "POST", "/api/users/"+uuid.NewString()+"/mute", nil), http.StatusNotFound)
+	})
+
+	t.Run("Blocking twice, then unblocking twice", func(t *testing.T) {
+		state, handler := setup(t)
+		expectStatus(t, apiCall(t, handler, keys, alice.ID, "POST", "/api/users/"+bob.ID.String()+"/block", nil), http.StatusNoContent)
+		expectStatus(t, apiCall(t, handler, keys, alice.ID, "POST", "/api/users/"+bob.ID.String()+"/block", nil), http.StatusNoContent)
+		if !state.blocks[[2]string{alice.ID.String(), bob.ID.String()}] {
+			t.Fatalf("Expected alice to block bob")
+		}
+		expectStatus(t, apiCall(t, handler, keys, alice.ID, "DELETE", "/api/users/"+bob.ID.String()+"/block", nil), http.StatusNoContent)
+		expectStatus(t, apiCall(t, handler, keys, alice.ID, "DELETE", "/api/users/"+bob.ID.String()+"/block", nil), http.StatusNotFound)
+	})
+
+	t.Run("Muting twice, then unmuting twice", func(t *testing.T) {
+		_, handler := setup(t)
+		expectStatus(t, apiCall(t, handler, keys, alice.ID, "POST", "/api/users/"+bob.ID.String()+"/mute", nil), http.StatusNoContent)
+		expectStatus(t, apiCall(t, handler, keys, alice.ID, "POST", "/api/users/"+bob.ID.String()+"/mute", nil), http.StatusNoContent)
+		expectStatus(t, apiCall(t, handler, keys, alice.ID, "DELETE", "/api/users/"+bob.ID.String()+"/mute", nil), http.StatusNoContent)
+		expectStatus(t, apiCall(t, handler, keys, alice.ID, "DELETE", "/api/users/"+bob.ID.String()+"/mute", nil), http.StatusNotFound)
+	})
+
+	t.Run("Blocking removes follows both ways", func(t *testing.T) {
+		state, handler := setup(t)
+		expectStatus(t, apiCall(t, handler, keys, alice.ID, "POST", "/api/users/"+bob.ID.String()+"/follow", nil), http.StatusNoContent)
+		expectStatus(t, apiCall(t, handler, keys, bob.ID, "POST", "/api/users/"+alice.ID.String()+"/follow", nil), http.StatusNoContent)
+		expectStatus(t, apiCall(t, handler, keys, alice.ID, "POST", "/api/users/"+bob.ID.String()+"/block", nil), http.StatusNoContent)
+		if len(state.follows) != 0 {
+			t.Fatalf("Expected no follows left, got %v", state.follows)
+		}
+	})
+
+	t.Run("Blocks stop follows both ways", func(t *testing.T) {
+		_, handler := setup(t)
+		expectStatus(t, apiCall(t, handler, keys, alice.ID, "POST", "/api/users/"+bob.ID.String()+"/block", nil), http.StatusNoContent)
+		expectStatus(t, apiCall(t, handler, keys, bob.ID, "POST", "/api/users/"+alice.ID.String()+"/follow", nil), http.StatusForbidden)
+		expectStatus(t, apiCall(t, handler, keys, alice.ID, "POST", "/api/users/"+bob.ID.String()+"/follow", nil), http.StatusForbidden)
+		expectStatus(t, apiCall(t, handler, keys, carol.ID, "POST", "/api/users/"+bob.ID.String()+"/follow", nil), http.StatusNoContent)
+	})
+
+	t.Run("Blocks stop replies both ways", func(t *testing.T) {
+		state, handler := setup(t)
+		byAlice := state.addChirp(alice, "Hello", uuid.NullUUID{})
+		byBob := state.addChirp(bob, "Hello", uuid.NullUUID{})
+		expectStatus(t, apiCall(t, handler, keys, alice.ID, "POST", "/api/users/"+bob.ID.String()+"/block", nil), http.StatusNoContent)
+		expectStatus(t, apiCall(t, handler, keys, bob.ID, "POST", "/api/chirps", createChirpyDto{Body: "Hi", InReplyTo: &byAlice.ID}), http.StatusForbidden)
+		expectStatus(t, apiCall(t, handler, keys, alice.ID, "POST", "/api/chirps", createChirpyDto{Body: "Hi", InReplyTo: &byBob.ID}), http.StatusForbidden)
+	})
+
+	t.Run("Mentions only notify the users the SQL links", func(t *testing.T) {
+		state, handler := setup(t)
+		state.mentioned = []uuid.UUID{carol.ID}
+		rec := apiCall(t, handler, keys, bob.ID, "POST", "/api/chirps", createChirpyDto{Body: "Hi @Alice and @carol"})
+		expectStatus(t, rec, http.StatusCreated)
+
+		chirp := decodeResponse[ChirpResponse](t, rec)
+		args := state.args["CreateMentions"]
+		if args[0] != chirp.ID.String() || args[1] != "{\"alice\",\"carol\"}" {
+			t.Fatalf("Expected the chirp and its lowercased usernames, got %v", args)
+		}
+		if len(state.notifications) != 1 || state.notifications[0].UserID != carol.ID {
+			t.Fatalf("Expected only carol to be notified, got %+v", state.notifications)
+		}
+	})
+
+	t.Run("Listings are filtered for the caller", func(t *testing.T) {
+		state, handler := setup(t)
+		state.addChirp(alice, "Hello", uuid.NullUUID{})
+		state.addChirp(bob, "Hello", uuid.NullUUID{})
+
+		for _, listing := range []struct {
+			target string
+			query  string
+			viewer int
+		}{
+			{"/api/chirps?sort=asc", "ListChirpsAsc", 1},
+			{"/api/chirps?sort=desc", "ListChirpsDesc", 1},
+			{"/api/timeline?sort=asc", "ListTimelineAsc", 0},
+			{"/api/timeline?sort=desc", "ListTimelineDesc", 0},
+		} {
+			rec := apiCall(t, handler, keys, alice.ID, "GET", listing.target, nil)
+			expectStatus(t, rec, http.StatusOK)
+			if got := decodeResponse[[]ChirpResponse](t, rec); len(got) != 2 {
+				t.Fatalf("Expected %s to return the rows of %s, got %v", listing.target, listing.query, authors(got))
+			}
+			if viewer := state.args[listing.query][listing.viewer]; viewer != alice.ID.String() {
+				t.Fatalf("Expected %s to filter for alice, got %v", listing.query, viewer)
+			}
+		}
+
+		for _, sort := range []string{"Asc", "Desc"} {
+			rec := apiCall(t, handler, keys, uuid.Nil, "GET", "/api/chirps?sort="+strings.ToLower(sort), nil)
+			expectStatus(t, rec, http.StatusOK)
+			if viewer := state.args["ListChirps"+sort][1]; viewer != nil {
+				t.Fatalf("Expected anonymous listings not to be filtered, got viewer %v", viewer)
+			}
+		}
+	})
+
+	t.Run("Threads are filtered for the caller", func(t *testing.T) {
+		state, handler := setup(t)
+		root := state.addChirp(carol, "Hello", uuid.NullUUID{})
+		state.addChirp(carol, "Hello", uuid.NullUUID{UUID: root.ID, Valid: true})
+
+		rec := apiCall(t, handler, keys, alice.ID, "GET", "/api/chirps/"+root.ID.String()+"/thread", nil)
+		expectStatus(t, rec, http.StatusOK)
+		if thread := decodeResponse[ThreadResponse](t, rec); len(thread.Replies) != 1 {
+			t.Fatalf("Expected the replies of ListReplyTree, got %+v", thread.Replies)
+		}
+		args := state.args["ListReplyTree"]
+		if args[0] != root.ID.String() || args[1] != alice.ID.String() {
+			t.Fatalf("Expected the replies to %s filtered for alice, got %v", root.ID, args)
+		}
+
+		rec = apiCall(t, handler, keys, uuid.Nil, "GET", "/api/chirps/"+root.ID.String()+"/thread", nil)
+		expectStatus(t, rec, http.StatusOK)
+		if viewer := state.args["ListReplyTree"][1]; viewer != nil {
+			t.Fatalf("Expected anonymous threads not to be filtered, got viewer %v", viewer)
+		}
+	})
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+	"github.com/sheltonFr/bootdev/chirspy/internal/entities"
+)
+
+type ChirpResponse struct {
+	ID              uuid.UUID         `json:"id"`
+	Body            string            `json:"body"`
+	UserID          uuid.UUID         `json:"user_id"`
+	Kind            string            `json:"kind"`
+	InReplyTo       *uuid.UUID        `json:"in_reply_to"`
+	ReferencedChirp *ReferencedChirp  `json:"referenced_chirp,omitempty"`
+	Entities        []EntityResponse  `json:"entities"`
+	Media           []MediaResponse   `json:"media"`
+	ReplyCount      int32             `json:"reply_count"`
+	Reactions       []ReactionSummary `json:"reactions"`
+	Deleted         bool              `json:"deleted,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
+
+// ReferencedChirp is the chirp a rechirp or quote points at, embedded one
+// level deep. When the original is gone it is marked unavailable and Chirp is
+// left out.
+type ReferencedChirp struct {
+	ID          *uuid.UUID     `json:"id"`
+	Unavailable bool           `json:"unavailable"`
+	Chirp       *ChirpResponse `json:"chirp,omitempty"`
+}
+
+// EntityResponse locates a hashtag, mention or URL in a chirp body. Start and
+// End are offsets in characters (runes), End being exclusive. UserID is set
+// on mentions of existing users.
+type EntityResponse struct {
+	Type   string     `json:"type"`
+	Text   string     `json:"text"`
+	Start  int        `json:"start"`
+	End    int        `json:"end"`
+	UserID *uuid.UUID `json:"user_id,omitempty"`
+}
+
+// ReactionSummary counts the reactions to a chirp with one emoji. Reacted is
+// true when the caller is one of the users who reacted.
+type ReactionSummary struct {
+	Emoji   string `json:"emoji"`
+	Count   int64  `json:"count"`
+	Reacted bool   `json:"reacted"`
+}
+
+type ThreadResponse struct {
+	Chirp      ChirpResponse   `json:"chirp"`
+	Ancestors  []ChirpResponse `json:"ancestors"`
+	Replies    []ReplyResponse `json:"replies"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// ReplyResponse is a chirp of a reply tree. Depth is 1 for direct replies to
+// the thread's chirp, 2 for replies to those, and so on.
+type ReplyResponse struct {
+	ChirpResponse
+	Depth int32 `json:"depth"`
+}
+
+func mapChirp(dbChirp database.Chirp) ChirpResponse {
+	chirp := ChirpResponse{
+		ID:         dbChirp.ID,
+		Body:       dbChirp.Body,
+		UserID:     dbChirp.UserID,
+		Kind:       dbChirp.Kind,
+		Entities:   mapEntities(dbChirp.Body),
+		Media:      []MediaResponse{},
+		ReplyCount: dbChirp.ReplyCount,
+		Reactions:  []ReactionSummary{},
+		Deleted:    dbChirp.DeletedAt.Valid,
+		CreatedAt:  dbChirp.CreatedAt,
+		UpdatedAt:  dbChirp.UpdatedAt,
+	}
+	if dbChirp.InReplyTo.Valid {
+		chirp.InReplyTo = &dbChirp.InReplyTo.UUID
+	}
+	if dbChirp.Kind != chirpKindChirp {
+		// Filled in by buildChirpResponses once the original is loaded.
+		chirp.ReferencedChirp = &ReferencedChirp{Unavailable: true}
+		if dbChirp.ReferencedChirpID.Valid {
+			chirp.ReferencedChirp.ID = &dbChirp.ReferencedChirpID.UUID
+		}
+	}
+	return chirp
+}
+
+func mapEntities(body string) []EntityResponse {
+	parsed := entities.Parse(body)
+	mapped := make([]EntityResponse, len(parsed))
+	for i, entity := range parsed {
+		mapped[i] = EntityResponse{
+			Type:  entity.Type,
+			Text:  entity.Text,
+			Start: entity.Start,
+			End:   entity.End,
+		}
+	}
+	return mapped
+}
+
+func mapChirps(dbChirp []database.Chirp) []ChirpResponse {
+	chirps := make([]ChirpResponse, len(dbChirp))
+
+	for i, chirp := range dbChirp {
+		chirps[i] = mapChirp(chirp)
+	}
+
+	return chirps
+}
+
+// buildChirpResponses maps chirps together with their reactions, as seen by
+// viewerID, their media, the users they mention and the chirps they
+// reference. Related rows are loaded with one query per kind for the whole
+// slice rather than one per chirp.
+func (c *chirpyHandler) buildChirpResponses(ctx context.Context, dbChirps []database.Chirp, viewerID uuid.NullUUID) ([]ChirpResponse, error) {
+	chirps := mapChirps(dbChirps)
+	if len(chirps) == 0 {
+		return chirps, nil
+	}
+
+	var referencedIDs []uuid.UUID
+	for _, chirp := range dbChirps {
+		if chirp.ReferencedChirpID.Valid {
+			referencedIDs = append(referencedIDs, chirp.ReferencedChirpID.UUID)
+		}
+	}
+	var referenced []ChirpResponse
+	if len(referencedIDs) > 0 {
+		dbReferenced, err := c.db.GetChirpsByIDs(ctx, referencedIDs)
+		if err != nil {
+			return nil, err
+		}
+		referenced = mapChirps(dbReferenced)
+	}
+
+	// Reactions, media and mentions are loaded for the chirps and the ones they embed
+	// at once.
+	all := make([]*ChirpResponse, 0, len(chirps)+len(referenced))
+	for i := range chirps {
+		all = append(all, &chirps[i])
+	}
+	for i := range referenced {
+		all = append(all, &referenced[i])
+	}
+
+	ids := make([]uuid.UUID, len(all))
+	byID := make(map[uuid.UUID][]*ChirpResponse, len(all))
+	for i, chirp := range all {
+		ids[i] = chirp.ID
+		byID[chirp.ID] = append(byID[chirp.ID], chirp)
+	}
+
+	summaries, err := c.db.GetReactionSummaries(ctx, database.GetReactionSummariesParams{
+		ViewerID: viewerID,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, summary := range summaries {
+		for _, chirp := range byID[summary.ChirpID] {
+			chirp.Reactions = append(chirp.Reactions, ReactionSummary{summary.Emoji, summary.Count, summary.Reacted})
+		}
+	}
+
+	attachments, err := c.db.GetChirpMedia(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, attachment := range attachments {
+		for _, chirp := range byID[attachment.ChirpID] {
+			chirp.Media = append(chirp.Media, mapMedia(c.storage, attachment.Medium, attachment.AltText))
+		}
+	}
+
+	mentions, err := c.db.GetChirpMentions(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, mention := range mentions {
+		if !mention.Username.Valid {
+			continue
+		}
+		for _, chirp := range byID[mention.ChirpID] {
+			resolveMention(chirp, mention.Username.String, mention.UserID)
+		}
+	}
+
+	originals := make(map[uuid.UUID]*ChirpResponse, len(referenced))
+	for i := range referenced {
+		if !referenced[i].Deleted {
+			referenced[i].ReferencedChirp = nil
+			originals[referenced[i].ID] = &referenced[i]
+		}
+	}
+	for i := range chirps {
+		ref := chirps[i].ReferencedChirp
+		if ref == nil || ref.ID == nil {
+			continue
+		}
+		if original, ok := originals[*ref.ID]; ok {
+			ref.Unavailable = false
+			ref.Chirp = original
+		}
+	}
+
+	return chirps, nil
+}
+
+// resolveMention links the mentions of username in chirp to the user.
+func resolveMention(chirp *ChirpResponse, username string, userID uuid.UUID) {
+	for i := range chirp.Entities {
+		entity := &chirp.Entities[i]
+		if entity.Type == entities.TypeMention && strings.EqualFold(entity.Text, username) {
+			entity.UserID = &userID
+		}
+	}
+}
+
+func (c *chirpyHandler) buildChirpResponse(ctx context.Context, dbChirp database.Chirp, viewerID uuid.NullUUID) (ChirpResponse, error) {
+	chirps, err := c.buildChirpResponses(ctx, []database.Chirp{dbChirp}, viewerID)
+	if err != nil {
+		return ChirpResponse{}, err
+	}
+	return chirps[0], nil
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+	"github.com/sheltonFr/bootdev/chirspy/internal/pagination"
+	"github.com/sheltonFr/bootdev/chirspy/internal/utils"
+)
+
+const (
+	searchOrderRelevance = "relevance"
+	searchOrderRecent    = "recent"
+
+	maxSearchQueryLength = 200
+	maxSearchOffset      = 1000
+
+	// Delimiters the search queries put around highlighted terms.
+	highlightStart = '\u0002'
+	highlightStop  = '\u0003'
+)
+
+// SearchResult is a chirp matching a search, with an excerpt of its body.
+// Highlights are the rune offsets of the matched terms in Snippet.
+type SearchResult struct {
+	Chirp      ChirpResponse `json:"chirp"`
+	Rank       float64       `json:"rank"`
+	Snippet    string        `json:"snippet"`
+	Highlights []Highlight   `json:"highlights"`
+}
+
+type Highlight struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+type searchFilters struct {
+	query    string
+	authorID uuid.NullUUID
+	since    sql.NullTime
+	until    sql.NullTime
+}
+
+// SearchChirps runs a full-text search over chirps. q accepts web search
+// syntax: "quoted phrases", or and -excluded words. Results can be narrowed
+// with author_id, since and until (RFC 3339) and ordered by relevance, the
+// default, or recent. Relevance pages are addressed with offset, recent
+// pages with cursor; using the other parameter is an error.
+func (c *chirpyHandler) SearchChirps(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filters, err := parseSearchFilters(query)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, ok := parseDescPage(w, r, "Search results can only be sorted desc")
+	if !ok {
+		return
+	}
+
+	viewerID := optionalUserID(r, c.keys)
+	var rows []database.SearchChirpsByRankRow
+	var offset int
+	switch order := query.Get("order"); order {
+	case "", searchOrderRelevance:
+		if page.Cursor != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "cursor can only be used with order=recent, use offset")
+			return
+		}
+		if raw := query.Get("offset"); raw != "" {
+			offset, err = strconv.Atoi(raw)
+			if err != nil || offset < 0 || offset > maxSearchOffset {
+				utils.RespondWithError(w, http.StatusBadRequest, "offset must be between 0 and "+strconv.Itoa(maxSearchOffset))
+				return
+			}
+		}
+		rows, err = c.db.SearchChirpsByRank(r.Context(), database.SearchChirpsByRankParams{
+			Query:    filters.query,
+			AuthorID: filters.authorID,
+			Since:    filters.since,
+			Until:    filters.until,
+			ViewerID: viewerID,
+			Limit:    page.Limit + 1,
+			Offset:   int32(offset),
+		})
+	case searchOrderRecent:
+		if query.Has("offset") {
+			utils.RespondWithError(w, http.StatusBadRequest, "offset can only be used with order=relevance, use cursor")
+			return
+		}
+		cursorCreatedAt, cursorID := page.CursorArgs()
+		var recent []database.SearchChirpsByRecencyRow
+		recent, err = c.db.SearchChirpsByRecency(r.Context(), database.SearchChirpsByRecencyParams{
+			Query:           filters.query,
+			AuthorID:        filters.authorID,
+			Since:           filters.since,
+			Until:           filters.until,
+			ViewerID:        viewerID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           page.Limit + 1,
+		})
+		for _, row := range recent {
+			rows = append(rows, database.SearchChirpsByRankRow(row))
+		}
+	default:
+		utils.RespondWithError(w, http.StatusBadRequest, "order must be relevance or recent")
+		return
+	}
+	if err != nil {
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not search chirps")
+		return
+	}
+
+	rows, hasMore := pagination.Trim(rows, page)
+	chirps := make([]database.Chirp, len(rows))
+	for i, row := range rows {
+		chirps[i] = row.Chirp
+	}
+	responses, err := c.buildChirpResponses(r.Context(), chirps, viewerID)
+	if err != nil {
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not search chirps")
+		return
+	}
+
+	results := make([]SearchResult, len(rows))
+	for i, row := range rows {
+		snippet, highlights := parseSnippet(row.Snippet)
+		results[i] = SearchResult{
+			Chirp:      responses[i],
+			Rank:       row.Rank,
+			Snippet:    snippet,
+			Highlights: highlights,
+		}
+	}
+
+	if hasMore {
+		if query.Get("order") == searchOrderRecent {
+			last := chirps[len(chirps)-1]
+			w.Header().Set("Link", pagination.NextLink(r.URL, pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}))
+		} else if next := offset + len(rows); next <= maxSearchOffset {
+			w.Header().Set("Link", pagination.NextOffsetLink(r.URL, next))
+		}
+	}
+	utils.RespondWithJSON(w, http.StatusOK, results)
+}
+
+func parseSearchFilters(query url.Values) (searchFilters, error) {
+	filters := searchFilters{query: strings.TrimSpace(query.Get("q"))}
+	if filters.query == "" {
+		return searchFilters{}, errors.New("q is required")
+	}
+	if utf8.RuneCountInString(filters.query) > maxSearchQueryLength {
+		return searchFilters{}, errors.New("q is too long")
+	}
+
+	if authorID := query.Get("author_id"); authorID != "" {
+		id, err := uuid.Parse(authorID)
+		if err != nil {
+			return searchFilters{}, errors.New("Invalid author_id")
+		}
+		filters.authorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	var err error
+	if filters.since, err = parseTimeParam(query, "since"); err != nil {
+		return searchFilters{}, err
+	}
+	if filters.until, err = parseTimeParam(query, "until"); err != nil {
+		return searchFilters{}, err
+	}
+	return filters, nil
+}
+
+func parseTimeParam(query url.Values, name string) (sql.NullTime, error) {
+	value := query.Get(name)
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return sql.NullTime{}, errors.New(name + " must be an RFC 3339 timestamp")
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}, nil
+}
+
+// parseSnippet strips the highlight delimiters from a search snippet and
+// returns where they were.
+func parseSnippet(raw string) (string, []Highlight) {
+	var snippet strings.Builder
+	highlights := []Highlight{}
+	offset := 0
+	for _, r := range raw {
+		switch r {
+		case highlightStart:
+			highlights = append(highlights, Highlight{Start: offset, End: offset})
+		case highlightStop:
+			if n := len(highlights); n > 0 {
+				highlights[n-1].End = offset
+			}
+		default:
+			snippet.WriteRune(r)
+			offset++
+		}
+	}
+	return snippet.String(), highlights
+}
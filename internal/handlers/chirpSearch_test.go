@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseSnippet(t *testing.T) {
+	snippet, highlights := parseSnippet("the \u0002café\u0003 near the \u0002station\u0003")
+
+	if snippet != "the café near the station" {
+		t.Fatalf("Unexpected snippet %q", snippet)
+	}
+	expected := []Highlight{{Start: 4, End: 8}, {Start: 18, End: 25}}
+	if !slices.Equal(highlights, expected) {
+		t.Fatalf("Expected %v, got %v", expected, highlights)
+	}
+}
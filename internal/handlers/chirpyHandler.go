@@ -1,89 +1,133 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"slices"
 	"strings"
-	"time"
 	"unicode/utf8"
 
 	"github.com/google/uuid"
+	"github.com/sheltonFr/bootdev/chirspy/internal/activitypub"
 	"github.com/sheltonFr/bootdev/chirspy/internal/auth"
 	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+	"github.com/sheltonFr/bootdev/chirspy/internal/entities"
+	"github.com/sheltonFr/bootdev/chirspy/internal/media"
+	"github.com/sheltonFr/bootdev/chirspy/internal/notifications"
+	"github.com/sheltonFr/bootdev/chirspy/internal/pagination"
+	"github.com/sheltonFr/bootdev/chirspy/internal/stream"
 	"github.com/sheltonFr/bootdev/chirspy/internal/utils"
+	"github.com/sheltonFr/bootdev/chirspy/internal/webhooks"
 )
 
 type chirpyHandler struct {
-	db        *database.Queries
-	logger    *log.Logger
-	jwtSecret string
+	db         *database.Queries
+	conn       *sql.DB
+	logger     *log.Logger
+	keys       *auth.KeySet
+	storage    media.Storage
+	hub        *stream.Hub
+	notifier   *notifications.Notifier
+	federation *activitypub.Federation
+	webhooks   *webhooks.Dispatcher
 }
 
-type createChirpyDto struct {
-	Body string `json:"body"`
-}
+const (
+	chirpKindChirp   = "chirp"
+	chirpKindRechirp = "rechirp"
+	chirpKindQuote   = "quote"
 
-type ChirpResponse struct {
-	ID        uuid.UUID `json:"id"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-}
+	maxChirpMedia    = 4
+	maxAltTextLength = 1000
 
-func mapChirp(dbChirp database.Chirp) ChirpResponse {
-	return ChirpResponse{
-		ID:        dbChirp.ID,
-		Body:      dbChirp.Body,
-		UserID:    dbChirp.UserID,
-		CreatedAt: dbChirp.CreatedAt,
-		UpdatedAt: dbChirp.UpdatedAt,
-	}
-}
+	rechirpIndex    = "chirps_user_id_rechirp_idx"
+	chirpMediaIndex = "chirp_media_media_id_key"
+)
 
-func mapChirps(dbChirp []database.Chirp) []ChirpResponse {
-	chirps := make([]ChirpResponse, len(dbChirp))
+// createChirpyDto describes a new chirp. RechirpOf reshares a chirp without a
+// body; QuoteOf reshares one with a body of its own.
+type createChirpyDto struct {
+	Body      string     `json:"body"`
+	InReplyTo *uuid.UUID `json:"in_reply_to"`
+	RechirpOf *uuid.UUID `json:"rechirp_of"`
+	QuoteOf   *uuid.UUID `json:"quote_of"`
+	// Media lists uploads from POST /api/media to attach, in display order.
+	Media []mediaAttachmentDto `json:"media"`
+}
 
-	for i, chirp := range dbChirp {
-		chirps[i] = mapChirp(chirp)
-	}
+type mediaAttachmentDto struct {
+	ID      uuid.UUID `json:"id"`
+	AltText string    `json:"alt_text"`
+}
 
-	return chirps
+type updateChirpyDto struct {
+	Body string `json:"body"`
 }
 
 func NewChirpyHandler(
 	db *database.Queries,
+	conn *sql.DB,
 	logger *log.Logger,
-	jwtSecret string) *chirpyHandler {
-	return &chirpyHandler{db, logger, jwtSecret}
+	keys *auth.KeySet,
+	storage media.Storage,
+	hub *stream.Hub,
+	notifier *notifications.Notifier,
+	federation *activitypub.Federation,
+	dispatcher *webhooks.Dispatcher) *chirpyHandler {
+	return &chirpyHandler{db, conn, logger, keys, storage, hub, notifier, federation, dispatcher}
 }
 
 func (c *chirpyHandler) CreateChirpy(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
-	userID, err := auth.ValidateJWT(token, c.jwtSecret)
-	if err != nil {
-		c.logger.Printf("Unauthorized: %v", err)
-		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+	userID, ok := authenticateRequest(w, r, c.keys)
+	if !ok {
 		return
 	}
 
 	chirpyDto := createChirpyDto{}
-	err = json.NewDecoder(r.Body).Decode(&chirpyDto)
+	err := json.NewDecoder(r.Body).Decode(&chirpyDto)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
 		return
 	}
 
-	if utf8.RuneCountInString(chirpyDto.Body) > 140 {
-		utils.RespondWithError(w, http.StatusBadRequest, "Chirp is too long")
+	chirpyParams := database.CreateChirpyParams{Kind: chirpKindChirp}
+	referencedID := chirpyDto.QuoteOf
+	switch {
+	case chirpyDto.RechirpOf != nil && chirpyDto.QuoteOf != nil:
+		utils.RespondWithError(w, http.StatusBadRequest, "A chirp cannot be both a rechirp and a quote")
 		return
+	case chirpyDto.RechirpOf != nil:
+		if chirpyDto.Body != "" || chirpyDto.InReplyTo != nil || len(chirpyDto.Media) > 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, "A rechirp cannot have a body, media or be a reply")
+			return
+		}
+		chirpyParams.Kind = chirpKindRechirp
+		referencedID = chirpyDto.RechirpOf
+	case chirpyDto.QuoteOf != nil:
+		if chirpyDto.Body == "" {
+			utils.RespondWithError(w, http.StatusBadRequest, "A quote needs a body")
+			return
+		}
+		chirpyParams.Kind = chirpKindQuote
 	}
 
-	cleanedChirp := replaceBadWords(chirpyDto.Body)
+	if chirpyParams.Kind != chirpKindRechirp {
+		chirpyParams.Body, err = validateChirpBody(chirpyDto.Body)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if err := validateMediaAttachments(chirpyDto.Media); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	user, err := c.db.GetUserByID(r.Context(), userID)
 	if err != nil {
@@ -91,45 +135,298 @@ func (c *chirpyHandler) CreateChirpy(w http.ResponseWriter, r *http.Request) {
 		utils.RespondWithError(w, http.StatusNotFound, "User not found")
 		return
 	}
+	chirpyParams.UserID = user.ID
+
+	if len(chirpyDto.Media) > 0 {
+		ids := make([]uuid.UUID, len(chirpyDto.Media))
+		for i, attachment := range chirpyDto.Media {
+			ids[i] = attachment.ID
+		}
+		available, err := c.db.GetUnattachedMedia(r.Context(), database.GetUnattachedMediaParams{
+			Ids:    ids,
+			UserID: user.ID,
+		})
+		if err != nil {
+			c.logger.Printf("DB error: %v\n", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not create chirpy")
+			return
+		}
+		if len(available) != len(ids) {
+			utils.RespondWithError(w, http.StatusBadRequest, "Media not found or already attached to a chirp")
+			return
+		}
+	}
 
-	chirpyParams := database.CreateChirpyParams{
-		Body:   cleanedChirp,
-		UserID: user.ID,
+	var parent database.Chirp
+	if chirpyDto.InReplyTo != nil {
+		parent, err = c.getLiveChirp(r.Context(), *chirpyDto.InReplyTo)
+		if err != nil {
+			c.respondWithLookupError(w, err, "Parent chirp not found")
+			return
+		}
+		blocked, err := c.db.IsBlockedBetween(r.Context(), database.IsBlockedBetweenParams{
+			UserA: user.ID,
+			UserB: parent.UserID,
+		})
+		if err != nil {
+			c.logger.Printf("DB error: %v\n", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not create chirpy")
+			return
+		}
+		if blocked {
+			utils.RespondWithError(w, http.StatusForbidden, "You can't reply to this user")
+			return
+		}
+		chirpyParams.InReplyTo = uuid.NullUUID{UUID: parent.ID, Valid: true}
 	}
 
-	created, err := c.db.CreateChirpy(r.Context(), chirpyParams)
+	if referencedID != nil {
+		referenced, err := c.getLiveChirp(r.Context(), *referencedID)
+		// Resharing a rechirp reshares the chirp it points at.
+		if err == nil && referenced.Kind == chirpKindRechirp {
+			referenced, err = c.getLiveChirp(r.Context(), referenced.ReferencedChirpID.UUID)
+		}
+		if err != nil {
+			c.respondWithLookupError(w, err, "Referenced chirp not found")
+			return
+		}
+		chirpyParams.ReferencedChirpID = uuid.NullUUID{UUID: referenced.ID, Valid: true}
+	}
+
+	var created database.Chirp
+	var mentioned []uuid.UUID
+	err = runInTx(r.Context(), c.conn, c.db, func(q *database.Queries) error {
+		created, err = q.CreateChirpy(r.Context(), chirpyParams)
+		if err != nil {
+			return err
+		}
+		if created.InReplyTo.Valid {
+			if err := q.IncrementReplyCount(r.Context(), created.InReplyTo.UUID); err != nil {
+				return err
+			}
+		}
+		for i, attachment := range chirpyDto.Media {
+			err := q.AttachMedia(r.Context(), database.AttachMediaParams{
+				ChirpID:  created.ID,
+				MediaID:  attachment.ID,
+				Position: int32(i),
+				AltText:  strings.TrimSpace(attachment.AltText),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		mentioned, err = syncEntities(r.Context(), q, created)
+		if err != nil {
+			return err
+		}
+		if err := c.federation.EnqueueCreate(r.Context(), q, created); err != nil {
+			return err
+		}
+		return c.webhooks.Enqueue(r.Context(), q, webhooks.EventChirpCreated, mapChirp(created))
+	})
 	if err != nil {
+		if isUniqueViolation(err) && violatedConstraint(err) == rechirpIndex {
+			utils.RespondWithError(w, http.StatusConflict, "You already rechirped this chirp")
+			return
+		}
+		if isUniqueViolation(err) && violatedConstraint(err) == chirpMediaIndex {
+			utils.RespondWithError(w, http.StatusConflict, "Media is already attached to a chirp")
+			return
+		}
 		c.logger.Printf("DB error: %v\n", err)
 		utils.RespondWithError(w, http.StatusInternalServerError, "Could not create chirpy")
 		return
 	}
-	utils.RespondWithJSON(w, http.StatusCreated, mapChirp(created))
+
+	response, err := c.buildChirpResponse(r.Context(), created, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve chirp")
+		return
+	}
+	c.publishChirp(created, response)
+	c.notifyChirp(r.Context(), created, uuid.NullUUID{UUID: parent.UserID, Valid: created.InReplyTo.Valid}, mentioned)
+	utils.RespondWithJSON(w, http.StatusCreated, response)
 }
 
+// GetAllChirps lists chirps using keyset pagination. It accepts the limit,
+// cursor, sort (asc|desc) and author_id query parameters and advertises the
+// next page through a Link header.
 func (c *chirpyHandler) GetAllChirps(w http.ResponseWriter, r *http.Request) {
-	chirps, err := c.db.GetChirps(r.Context())
+	query := r.URL.Query()
+	page, err := pagination.ParseParams(query, true)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	authorID := uuid.NullUUID{}
+	if authorIDString := query.Get("author_id"); authorIDString != "" {
+		id, err := uuid.Parse(authorIDString)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid author_id")
+			return
+		}
+		authorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	viewerID := optionalUserID(r, c.keys)
+	chirps, err := c.listChirps(r.Context(), authorID, viewerID, page)
+	if err != nil {
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch chirps")
+		return
+	}
+
+	c.respondWithChirpPage(w, r, chirps, page, viewerID)
+}
+
+// GetTimeline lists chirps from the accounts the caller follows, newest
+// first, with the same pagination parameters as GetAllChirps.
+func (c *chirpyHandler) GetTimeline(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticateRequest(w, r, c.keys)
+	if !ok {
+		return
+	}
+
+	page, err := pagination.ParseParams(r.URL.Query(), false)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cursorCreatedAt, cursorID := page.CursorArgs()
+	var chirps []database.Chirp
+	if page.Ascending {
+		chirps, err = c.db.ListTimelineAsc(r.Context(), database.ListTimelineAscParams{
+			FollowerID:      userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           page.Limit + 1,
+		})
+	} else {
+		chirps, err = c.db.ListTimelineDesc(r.Context(), database.ListTimelineDescParams{
+			FollowerID:      userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           page.Limit + 1,
+		})
+	}
+	if err != nil {
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch timeline")
+		return
+	}
+
+	c.respondWithChirpPage(w, r, chirps, page, uuid.NullUUID{UUID: userID, Valid: true})
+}
+
+// listChirps fetches one page of chirps plus one extra row, which callers use
+// to tell whether there is a next page. Chirps by users the viewer blocked or
+// muted are left out.
+func (c *chirpyHandler) listChirps(ctx context.Context, authorID, viewerID uuid.NullUUID, page pagination.Params) ([]database.Chirp, error) {
+	cursorCreatedAt, cursorID := page.CursorArgs()
+	if page.Ascending {
+		return c.db.ListChirpsAsc(ctx, database.ListChirpsAscParams{
+			AuthorID:        authorID,
+			ViewerID:        viewerID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           page.Limit + 1,
+		})
+	}
+	return c.db.ListChirpsDesc(ctx, database.ListChirpsDescParams{
+		AuthorID:        authorID,
+		ViewerID:        viewerID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		Limit:           page.Limit + 1,
+	})
+}
+
+// respondWithChirpPage writes a page of chirps fetched with one extra row and
+// sets the Link header when there is a next page.
+func (c *chirpyHandler) respondWithChirpPage(w http.ResponseWriter, r *http.Request, chirps []database.Chirp, page pagination.Params, viewerID uuid.NullUUID) {
+	chirps, hasMore := pagination.Trim(chirps, page)
+	response, err := c.buildChirpResponses(r.Context(), chirps, viewerID)
 	if err != nil {
 		c.logger.Printf("DB error: %v\n", err)
 		utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch chirps")
 		return
 	}
 
-	response := mapChirps(chirps)
+	if hasMore {
+		last := chirps[len(chirps)-1]
+		w.Header().Set("Link", pagination.NextLink(r.URL, pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}))
+	}
 	utils.RespondWithJSON(w, http.StatusOK, response)
 }
 
-func (c *chirpyHandler) GetChirpyById(w http.ResponseWriter, r *http.Request) {
-	chirpIDString := r.PathValue("chirpID")
-	if chirpIDString == "" {
-		utils.RespondWithError(w, http.StatusBadRequest, "Chirp ID is required")
+// GetChirpsByTag lists the chirps tagged with the hashtag in the path, newest
+// first. The tag may be given with or without its leading '#'.
+func (c *chirpyHandler) GetChirpsByTag(w http.ResponseWriter, r *http.Request) {
+	tag := entities.NormalizeTag(r.PathValue("tag"))
+	if tag == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Tag is required")
 		return
 	}
 
-	id, err := uuid.Parse(chirpIDString)
+	page, ok := parseDescPage(w, r, "Tag pages can only be sorted desc")
+	if !ok {
+		return
+	}
+
+	viewerID := optionalUserID(r, c.keys)
+	cursorCreatedAt, cursorID := page.CursorArgs()
+	chirps, err := c.db.ListChirpsByTag(r.Context(), database.ListChirpsByTagParams{
+		Tag:             tag,
+		ViewerID:        viewerID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		Limit:           page.Limit + 1,
+	})
 	if err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Invalid UUID format")
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch chirps")
 		return
+	}
 
+	c.respondWithChirpPage(w, r, chirps, page, viewerID)
+}
+
+// GetMentions lists the chirps mentioning the caller, newest first.
+func (c *chirpyHandler) GetMentions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticateRequest(w, r, c.keys)
+	if !ok {
+		return
+	}
+
+	page, ok := parseDescPage(w, r, "Mentions can only be sorted desc")
+	if !ok {
+		return
+	}
+
+	cursorCreatedAt, cursorID := page.CursorArgs()
+	chirps, err := c.db.ListMentions(r.Context(), database.ListMentionsParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		Limit:           page.Limit + 1,
+	})
+	if err != nil {
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch mentions")
+		return
+	}
+
+	c.respondWithChirpPage(w, r, chirps, page, uuid.NullUUID{UUID: userID, Valid: true})
+}
+
+func (c *chirpyHandler) GetChirpyById(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseChirpID(w, r)
+	if !ok {
+		return
 	}
 
 	chirp, err := c.db.GetChirpyByID(r.Context(), id)
@@ -142,8 +439,405 @@ func (c *chirpyHandler) GetChirpyById(w http.ResponseWriter, r *http.Request) {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve chirp")
 		return
 	}
+	if chirp.DeletedAt.Valid {
+		utils.RespondWithError(w, http.StatusNotFound, "Chirp Not Found")
+		return
+	}
+
+	response, err := c.buildChirpResponse(r.Context(), chirp, optionalUserID(r, c.keys))
+	if err != nil {
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve chirp")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// GetThread returns a chirp with the chain of chirps it replies to, root
+// first, and one page of the tree of replies below it, in depth-first order
+// with siblings in chronological order. Deleted chirps show up as tombstones
+// so the shape of the thread is preserved. Replies by users the caller blocked
+// or muted are left out, with the replies below them.
+func (c *chirpyHandler) GetThread(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseChirpID(w, r)
+	if !ok {
+		return
+	}
+	page, err := pagination.ParseParams(r.URL.Query(), true)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !page.Ascending {
+		utils.RespondWithError(w, http.StatusBadRequest, "Replies can only be sorted asc")
+		return
+	}
 
-	utils.RespondWithJSON(w, http.StatusOK, chirp)
+	chirp, err := c.db.GetChirpyByID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, http.StatusNotFound, "Chirp Not Found")
+			return
+		}
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve chirp")
+		return
+	}
+
+	ancestors, err := c.db.GetChirpAncestors(r.Context(), chirp.ID)
+	if err != nil {
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve thread")
+		return
+	}
+
+	viewerID := optionalUserID(r, c.keys)
+	_, cursorID := page.CursorArgs()
+	rows, err := c.db.ListReplyTree(r.Context(), database.ListReplyTreeParams{
+		ChirpID:  chirp.ID,
+		ViewerID: viewerID,
+		CursorID: cursorID,
+		Limit:    page.Limit + 1,
+	})
+	if err != nil {
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve thread")
+		return
+	}
+
+	rows, hasMore := pagination.Trim(rows, page)
+
+	// Map the whole thread in one batch and split it up again afterwards.
+	all := make([]database.Chirp, 0, len(ancestors)+1+len(rows))
+	all = append(all, ancestors...)
+	all = append(all, chirp)
+	for _, row := range rows {
+		all = append(all, row.Chirp)
+	}
+	responses, err := c.buildChirpResponses(r.Context(), all, viewerID)
+	if err != nil {
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve thread")
+		return
+	}
+
+	thread := ThreadResponse{
+		Ancestors: responses[:len(ancestors)],
+		Chirp:     responses[len(ancestors)],
+		Replies:   make([]ReplyResponse, len(rows)),
+	}
+	for i, row := range rows {
+		thread.Replies[i] = ReplyResponse{responses[len(ancestors)+1+i], row.Depth}
+	}
+	if hasMore {
+		last := rows[len(rows)-1].Chirp
+		thread.NextCursor = pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, thread)
+}
+
+func (c *chirpyHandler) UpdateChirpy(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticateRequest(w, r, c.keys)
+	if !ok {
+		return
+	}
+
+	id, ok := parseChirpID(w, r)
+	if !ok {
+		return
+	}
+
+	chirpyDto := updateChirpyDto{}
+	err := json.NewDecoder(r.Body).Decode(&chirpyDto)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	cleanedChirp, err := validateChirpBody(chirpyDto.Body)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	chirp, ok := c.getOwnedChirp(w, r, id, userID)
+	if !ok {
+		return
+	}
+	if chirp.Kind == chirpKindRechirp {
+		utils.RespondWithError(w, http.StatusBadRequest, "Rechirps cannot be edited")
+		return
+	}
+	if chirp.Kind == chirpKindQuote && strings.TrimSpace(cleanedChirp) == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "A quote needs a body")
+		return
+	}
+
+	var updated database.Chirp
+	var mentioned []uuid.UUID
+	err = runInTx(r.Context(), c.conn, c.db, func(q *database.Queries) error {
+		updated, err = q.UpdateChirpy(r.Context(), database.UpdateChirpyParams{
+			ID:   id,
+			Body: cleanedChirp,
+		})
+		if err != nil {
+			return err
+		}
+		mentioned, err = syncEntities(r.Context(), q, updated)
+		if err != nil {
+			return err
+		}
+		return c.webhooks.Enqueue(r.Context(), q, webhooks.EventChirpUpdated, mapChirp(updated))
+	})
+	if err != nil {
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not update chirp")
+		return
+	}
+
+	c.notifyChirp(r.Context(), updated, uuid.NullUUID{}, mentioned)
+
+	response, err := c.buildChirpResponse(r.Context(), updated, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve chirp")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+func (c *chirpyHandler) DeleteChirpy(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticateRequest(w, r, c.keys)
+	if !ok {
+		return
+	}
+
+	id, ok := parseChirpID(w, r)
+	if !ok {
+		return
+	}
+
+	chirp, ok := c.getOwnedChirp(w, r, id, userID)
+	if !ok {
+		return
+	}
+
+	var deletedMedia []database.Medium
+	err := runInTx(r.Context(), c.conn, c.db, func(q *database.Queries) error {
+		rows, err := q.DeleteChirpy(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			// A concurrent request deleted it first.
+			return sql.ErrNoRows
+		}
+		if chirp.InReplyTo.Valid {
+			if err := q.DecrementReplyCount(r.Context(), chirp.InReplyTo.UUID); err != nil {
+				return err
+			}
+		}
+		deletedMedia, err = q.DeleteChirpMedia(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		if err := c.federation.EnqueueDelete(r.Context(), q, chirp); err != nil {
+			return err
+		}
+		return c.webhooks.Enqueue(r.Context(), q, webhooks.EventChirpDeleted, mapChirp(chirp))
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusNotFound, "Chirp Not Found")
+		return
+	}
+	if err != nil {
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not delete chirp")
+		return
+	}
+
+	c.retractChirp(chirp)
+
+	// Attachments go away with the chirp's body. Files left behind by a
+	// failed delete are only logged: they are no longer referenced.
+	for _, medium := range deletedMedia {
+		for _, key := range []string{medium.StorageKey, medium.ThumbnailKey} {
+			if err := c.storage.Delete(r.Context(), key); err != nil {
+				c.logger.Printf("Storage error: %v\n", err)
+			}
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// publishChirp broadcasts a newly created chirp to the stream subscribers. A
+// new chirp has no reactions yet, so the author's view of it is the same as
+// everyone else's.
+func (c *chirpyHandler) publishChirp(chirp database.Chirp, response ChirpResponse) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		c.logger.Printf("Error marshaling chirp event: %v\n", err)
+		return
+	}
+	c.hub.Publish(stream.Event{
+		Type:     stream.TypeChirp,
+		Key:      chirp.ID,
+		Data:     data,
+		AuthorID: chirp.UserID,
+		Tags:     entities.Hashtags(chirp.Body),
+	})
+}
+
+// retractChirp tells the stream subscribers that a chirp was deleted and
+// keeps it from being replayed to clients that resume later. chirp is the
+// chirp as it was before the delete, so the event passes the same author and
+// hashtag filters.
+func (c *chirpyHandler) retractChirp(chirp database.Chirp) {
+	data, err := json.Marshal(map[string]uuid.UUID{"id": chirp.ID})
+	if err != nil {
+		c.logger.Printf("Error marshaling chirp event: %v\n", err)
+		return
+	}
+	c.hub.Forget(chirp.ID)
+	c.hub.Publish(stream.Event{
+		Type:     stream.TypeDelete,
+		Key:      chirp.ID,
+		Data:     data,
+		AuthorID: chirp.UserID,
+		Tags:     entities.Hashtags(chirp.Body),
+	})
+}
+
+// notifyChirp notifies replyTo, the author of the chirp a new reply answers,
+// and the users a chirp mentions for the first time. The replied-to author is
+// only told about the reply, not about being mentioned in it as well.
+func (c *chirpyHandler) notifyChirp(ctx context.Context, chirp database.Chirp, replyTo uuid.NullUUID, mentioned []uuid.UUID) {
+	chirpID := uuid.NullUUID{UUID: chirp.ID, Valid: true}
+	if replyTo.Valid {
+		c.notifier.Notify(ctx, notifications.KindReply, replyTo.UUID, chirp.UserID, chirpID, "")
+	}
+	for _, userID := range mentioned {
+		if replyTo.Valid && userID == replyTo.UUID {
+			continue
+		}
+		c.notifier.Notify(ctx, notifications.KindMention, userID, chirp.UserID, chirpID, "")
+	}
+}
+
+// getOwnedChirp loads the chirp with the given ID and checks that it exists,
+// isn't deleted and belongs to userID, writing a 404 or 403 response when it
+// doesn't.
+func (c *chirpyHandler) getOwnedChirp(w http.ResponseWriter, r *http.Request, id, userID uuid.UUID) (database.Chirp, bool) {
+	chirp, err := c.getLiveChirp(r.Context(), id)
+	if err != nil {
+		c.respondWithLookupError(w, err, "Chirp Not Found")
+		return database.Chirp{}, false
+	}
+
+	if chirp.UserID != userID {
+		utils.RespondWithError(w, http.StatusForbidden, "You are not the author of this chirp")
+		return database.Chirp{}, false
+	}
+	return chirp, true
+}
+
+// getLiveChirp loads a chirp that hasn't been deleted. Tombstones are
+// reported as sql.ErrNoRows.
+func (c *chirpyHandler) getLiveChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	chirp, err := c.db.GetChirpyByID(ctx, id)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if chirp.DeletedAt.Valid {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, nil
+}
+
+// respondWithLookupError answers a failed chirp lookup with a 404 carrying
+// notFoundMsg, or a 500 for unexpected errors.
+func (c *chirpyHandler) respondWithLookupError(w http.ResponseWriter, err error, notFoundMsg string) {
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusNotFound, notFoundMsg)
+		return
+	}
+	c.logger.Printf("DB error: %v\n", err)
+	utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve chirp")
+}
+
+// syncEntities links a chirp to the hashtags and the users mentioned in its
+// body, replacing the links it had before. It returns the users who are
+// mentioned for the first time.
+func syncEntities(ctx context.Context, q *database.Queries, chirp database.Chirp) ([]uuid.UUID, error) {
+	if err := q.DeleteChirpTags(ctx, chirp.ID); err != nil {
+		return nil, err
+	}
+	if tags := entities.Hashtags(chirp.Body); len(tags) > 0 {
+		err := q.TagChirp(ctx, database.TagChirpParams{
+			Names:   tags,
+			ChirpID: chirp.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	usernames := entities.Mentions(chirp.Body)
+	err := q.DeleteMentionsNotIn(ctx, database.DeleteMentionsNotInParams{
+		ChirpID:   chirp.ID,
+		Usernames: usernames,
+	})
+	if err != nil || len(usernames) == 0 {
+		return nil, err
+	}
+	return q.CreateMentions(ctx, database.CreateMentionsParams{
+		ChirpID:   chirp.ID,
+		Usernames: usernames,
+	})
+}
+
+func parseChirpID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	chirpIDString := r.PathValue("chirpID")
+	if chirpIDString == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Chirp ID is required")
+		return uuid.Nil, false
+	}
+
+	id, err := uuid.Parse(chirpIDString)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid UUID format")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// validateMediaAttachments checks the number of attachments, their alt text
+// and that none is listed twice.
+func validateMediaAttachments(attachments []mediaAttachmentDto) error {
+	if len(attachments) > maxChirpMedia {
+		return fmt.Errorf("A chirp can have at most %d media attachments", maxChirpMedia)
+	}
+	seen := make(map[uuid.UUID]bool, len(attachments))
+	for _, attachment := range attachments {
+		if seen[attachment.ID] {
+			return errors.New("Media attachments must be distinct")
+		}
+		seen[attachment.ID] = true
+		if utf8.RuneCountInString(attachment.AltText) > maxAltTextLength {
+			return fmt.Errorf("Alt text must be at most %d characters", maxAltTextLength)
+		}
+	}
+	return nil
+}
+
+// validateChirpBody enforces the chirp length limit and returns the body with
+// bad words replaced.
+func validateChirpBody(body string) (string, error) {
+	if utf8.RuneCountInString(body) > 140 {
+		return "", errors.New("Chirp is too long")
+	}
+	return replaceBadWords(body), nil
 }
 
 func replaceBadWords(chirp string) string {
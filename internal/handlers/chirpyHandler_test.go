@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"io"
+	"log"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+)
+
+func TestUpdateChirpy(t *testing.T) {
+	keys := newTestKeys(t)
+	userID := uuid.New()
+	quote := database.Chirp{
+		ID:                uuid.New(),
+		Body:              "Look at this",
+		UserID:            userID,
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
+		Kind:              chirpKindQuote,
+		ReferencedChirpID: uuid.NullUUID{UUID: uuid.New(), Valid: true},
+	}
+
+	fake, conn := newFakeDB(t)
+	fake.handle("GetChirpyByID", func(args []driver.Value) ([][]any, error) {
+		return one(quote), nil
+	})
+	handler := NewChirpyHandler(database.New(conn), conn, log.New(io.Discard, "", 0), keys, nil, nil, nil, nil, nil)
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", handler.UpdateChirpy)
+
+	for _, body := range []string{"", "   "} {
+		t.Run("Quote without a body", func(t *testing.T) {
+			rec := apiCall(t, mux, keys, userID, "PUT", "/api/chirps/"+quote.ID.String(), updateChirpyDto{Body: body})
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("Expected 400 for body %q, got %d", body, rec.Code)
+			}
+		})
+	}
+}
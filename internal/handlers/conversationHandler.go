@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/sheltonFr/bootdev/chirspy/internal/auth"
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+	"github.com/sheltonFr/bootdev/chirspy/internal/mappers"
+	"github.com/sheltonFr/bootdev/chirspy/internal/pagination"
+	"github.com/sheltonFr/bootdev/chirspy/internal/utils"
+)
+
+const maxMessageLength = 1000
+
+type conversationHandler struct {
+	db     *database.Queries
+	conn   *sql.DB
+	logger *log.Logger
+	keys   *auth.KeySet
+}
+
+func NewConversationHandler(db *database.Queries, conn *sql.DB, logger *log.Logger, keys *auth.KeySet) *conversationHandler {
+	return &conversationHandler{db, conn, logger, keys}
+}
+
+type createConversationDto struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+type createMessageDto struct {
+	Body string `json:"body"`
+}
+
+// ConversationResponse is a conversation as seen by one participant:
+// Participant is the other one, and UnreadCount counts the messages they
+// sent that the caller hasn't read yet.
+type ConversationResponse struct {
+	ID          uuid.UUID                  `json:"id"`
+	Participant mappers.PublicUserResponse `json:"participant"`
+	UnreadCount int64                      `json:"unread_count"`
+	CreatedAt   time.Time                  `json:"created_at"`
+	UpdatedAt   time.Time                  `json:"updated_at"`
+}
+
+type MessageResponse struct {
+	ID             uuid.UUID `json:"id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// CreateConversation starts a conversation between the caller and user_id.
+// Each pair of users has one conversation, so asking again returns the
+// existing one with 200 instead of 201.
+func (c *conversationHandler) CreateConversation(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticateRequest(w, r, c.keys)
+	if !ok {
+		return
+	}
+
+	var dto createConversationDto
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Could not parse Json")
+		return
+	}
+	if dto.UserID == userID {
+		utils.RespondWithError(w, http.StatusBadRequest, "You can't start a conversation with yourself")
+		return
+	}
+
+	if _, err := c.db.GetUserByID(r.Context(), dto.UserID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not create conversation")
+		return
+	}
+	if !c.checkNotBlocked(w, r, userID, dto.UserID) {
+		return
+	}
+
+	key := directKey(userID, dto.UserID)
+	var conversationID uuid.UUID
+	status := http.StatusCreated
+	err := runInTx(r.Context(), c.conn, c.db, func(q *database.Queries) error {
+		conversation, err := q.CreateConversation(r.Context(), key)
+		if errors.Is(err, sql.ErrNoRows) {
+			status = http.StatusOK
+			conversationID, err = q.GetConversationIDByDirectKey(r.Context(), key)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		conversationID = conversation.ID
+		for _, participant := range []uuid.UUID{userID, dto.UserID} {
+			err := q.AddConversationParticipant(r.Context(), database.AddConversationParticipantParams{
+				ConversationID: conversationID,
+				UserID:         participant,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not create conversation")
+		return
+	}
+
+	conversation, err := c.db.GetConversationForUser(r.Context(), database.GetConversationForUserParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve conversation")
+		return
+	}
+	utils.RespondWithJSON(w, status, mapConversation(conversation.Conversation, conversation.User, conversation.UnreadCount))
+}
+
+// ListConversations lists the caller's conversations, most recently active
+// first, using the limit and cursor query parameters.
+func (c *conversationHandler) ListConversations(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticateRequest(w, r, c.keys)
+	if !ok {
+		return
+	}
+
+	page, ok := parseDescPage(w, r, "Conversations can only be sorted desc")
+	if !ok {
+		return
+	}
+
+	cursorCreatedAt, cursorID := page.CursorArgs()
+	rows, err := c.db.ListConversations(r.Context(), database.ListConversationsParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		Limit:           page.Limit + 1,
+	})
+	if err != nil {
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch conversations")
+		return
+	}
+
+	rows, hasMore := pagination.Trim(rows, page)
+	response := make([]ConversationResponse, len(rows))
+	for i, row := range rows {
+		response[i] = mapConversation(row.Conversation, row.User, row.UnreadCount)
+	}
+	if hasMore {
+		last := rows[len(rows)-1].Conversation
+		w.Header().Set("Link", pagination.NextLink(r.URL, pagination.Cursor{CreatedAt: last.UpdatedAt, ID: last.ID}))
+	}
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// ListMessages lists the messages of a conversation, newest first. Fetching
+// the first page marks the conversation as read up to the newest message
+// returned; older pages leave the read marker alone.
+func (c *conversationHandler) ListMessages(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticateRequest(w, r, c.keys)
+	if !ok {
+		return
+	}
+	conversationID, ok := c.getParticipatingConversation(w, r, userID)
+	if !ok {
+		return
+	}
+
+	page, ok := parseDescPage(w, r, "Messages can only be sorted desc")
+	if !ok {
+		return
+	}
+
+	cursorCreatedAt, cursorID := page.CursorArgs()
+	messages, err := c.db.ListMessages(r.Context(), database.ListMessagesParams{
+		ConversationID:  conversationID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		Limit:           page.Limit + 1,
+	})
+	if err != nil {
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch messages")
+		return
+	}
+
+	messages, hasMore := pagination.Trim(messages, page)
+	if page.Cursor == nil && len(messages) > 0 {
+		err = c.db.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+			ReadAt:         messages[0].CreatedAt,
+			ConversationID: conversationID,
+			UserID:         userID,
+		})
+		if err != nil {
+			c.logger.Printf("DB error: %v\n", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch messages")
+			return
+		}
+	}
+
+	response := make([]MessageResponse, len(messages))
+	for i, message := range messages {
+		response[i] = mapMessage(message)
+	}
+	if hasMore {
+		last := messages[len(messages)-1]
+		w.Header().Set("Link", pagination.NextLink(r.URL, pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}))
+	}
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// CreateMessage sends a message to a conversation the caller takes part in,
+// unless one of the participants has blocked the other.
+func (c *conversationHandler) CreateMessage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticateRequest(w, r, c.keys)
+	if !ok {
+		return
+	}
+	conversationID, ok := c.getParticipatingConversation(w, r, userID)
+	if !ok {
+		return
+	}
+
+	var dto createMessageDto
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Could not parse Json")
+		return
+	}
+	body := strings.TrimSpace(dto.Body)
+	if body == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Message body is required")
+		return
+	}
+	if utf8.RuneCountInString(body) > maxMessageLength {
+		utils.RespondWithError(w, http.StatusBadRequest, "Message is too long")
+		return
+	}
+
+	recipientID, err := c.db.GetOtherParticipantID(r.Context(), database.GetOtherParticipantIDParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not send message")
+		return
+	}
+	if !c.checkNotBlocked(w, r, userID, recipientID) {
+		return
+	}
+
+	var message database.Message
+	err = runInTx(r.Context(), c.conn, c.db, func(q *database.Queries) error {
+		var err error
+		message, err = q.CreateMessage(r.Context(), database.CreateMessageParams{
+			ConversationID: conversationID,
+			SenderID:       userID,
+			Body:           body,
+		})
+		if err != nil {
+			return err
+		}
+		return q.TouchConversation(r.Context(), conversationID)
+	})
+	if err != nil {
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not send message")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusCreated, mapMessage(message))
+}
+
+// getParticipatingConversation parses the conversation ID in the path and
+// checks that userID takes part in it. Other users get a 404, so they can't
+// tell whether the conversation exists.
+func (c *conversationHandler) getParticipatingConversation(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (uuid.UUID, bool) {
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid UUID format")
+		return uuid.Nil, false
+	}
+
+	participating, err := c.db.IsConversationParticipant(r.Context(), database.IsConversationParticipantParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve conversation")
+		return uuid.Nil, false
+	}
+	if !participating {
+		utils.RespondWithError(w, http.StatusNotFound, "Conversation not found")
+		return uuid.Nil, false
+	}
+	return conversationID, true
+}
+
+// checkNotBlocked writes a 403 response and returns false when either user
+// has blocked the other.
+func (c *conversationHandler) checkNotBlocked(w http.ResponseWriter, r *http.Request, userID, otherID uuid.UUID) bool {
+	blocked, err := c.db.IsBlockedBetween(r.Context(), database.IsBlockedBetweenParams{
+		UserA: userID,
+		UserB: otherID,
+	})
+	if err != nil {
+		c.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not check blocks")
+		return false
+	}
+	if blocked {
+		utils.RespondWithError(w, http.StatusForbidden, "You can't message this user")
+		return false
+	}
+	return true
+}
+
+// directKey identifies the conversation between two users regardless of who
+// started it.
+func directKey(a, b uuid.UUID) string {
+	if b.String() < a.String() {
+		a, b = b, a
+	}
+	return a.String() + ":" + b.String()
+}
+
+func mapConversation(conversation database.Conversation, participant database.User, unreadCount int64) ConversationResponse {
+	return ConversationResponse{
+		ID:          conversation.ID,
+		Participant: mappers.MapPublicUser(&participant),
+		UnreadCount: unreadCount,
+		CreatedAt:   conversation.CreatedAt,
+		UpdatedAt:   conversation.UpdatedAt,
+	}
+}
+
+func mapMessage(message database.Message) MessageResponse {
+	return MessageResponse{
+		ID:             message.ID,
+		ConversationID: message.ConversationID,
+		SenderID:       message.SenderID,
+		Body:           message.Body,
+		CreatedAt:      message.CreatedAt,
+	}
+}
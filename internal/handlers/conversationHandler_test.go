@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sheltonFr/bootdev/chirspy/internal/auth"
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+)
+
+func TestDirectKey(t *testing.T) {
+	a := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	b := uuid.MustParse("22222222-2222-2222-2222-222222222222")
+
+	expected := a.String() + ":" + b.String()
+	if key := directKey(a, b); key != expected {
+		t.Errorf("Expected %q, got %q", expected, key)
+	}
+	if key := directKey(b, a); key != expected {
+		t.Errorf("Expected %q whatever the order, got %q", expected, key)
+	}
+}
+
+func newTestKeys(t *testing.T) *auth.KeySet {
+	t.Helper()
+	keys, err := auth.NewKeySet("test-secret")
+	if err != nil {
+		t.Fatalf("Failed to make key set: %v", err)
+	}
+	return keys
+}
+
+// apiCall sends a request with a JSON body through handler, authenticated as
+// userID unless it is uuid.Nil.
+func apiCall(t *testing.T, handler http.Handler, keys *auth.KeySet, userID uuid.UUID, method, target string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	if userID != uuid.Nil {
+		token, err := keys.MakeJWT(userID, time.Hour)
+		if err != nil {
+			t.Fatalf("Failed to make JWT: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeResponse[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("Failed to decode response %q: %v", rec.Body.String(), err)
+	}
+	return v
+}
+
+func newTestUser(name string) database.User {
+	return database.User{
+		ID:        uuid.New(),
+		Email:     name + "@example.com",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Role:      "user",
+	}
+}
+
+// conversationDB answers the queries of direct messages from memory. Its
+// clock moves a second with every message, so they are ordered.
+type conversationDB struct {
+	now           time.Time
+	users         map[string]database.User
+	blocks        map[[2]string]bool
+	conversations []*database.Conversation
+	lastRead      map[[2]string]*time.Time
+	messages      []database.Message
+}
+
+func newConversationDB(users ...database.User) *conversationDB {
+	return &conversationDB{
+		now:      time.Now().Truncate(time.Second),
+		users:    usersByID(users...),
+		blocks:   map[[2]string]bool{},
+		lastRead: map[[2]string]*time.Time{},
+	}
+}
+
+func (d *conversationDB) register(fake *fakeDB) {
+	handleUsers(fake, d.users)
+	handleBlocks(fake, d.blocks)
+	fake.handle("CreateConversation", func(args []driver.Value) ([][]any, error) {
+		for _, conversation := range d.conversations {
+			if conversation.DirectKey == args[0].(string) {
+				return nil, nil
+			}
+		}
+		conversation := &database.Conversation{ID: uuid.New(), DirectKey: args[0].(string), CreatedAt: d.now, UpdatedAt: d.now}
+		d.conversations = append(d.conversations, conversation)
+		return one(*conversation), nil
+	})
+	fake.handle("GetConversationIDByDirectKey", func(args []driver.Value) ([][]any, error) {
+		for _, conversation := range d.conversations {
+			if conversation.DirectKey == args[0].(string) {
+				return one(conversation.ID), nil
+			}
+		}
+		return nil, nil
+	})
+	fake.handle("AddConversationParticipant", func(args []driver.Value) ([][]any, error) {
+		d.lastRead[[2]string{args[0].(string), args[1].(string)}] = nil
+		return affected(1), nil
+	})
+	fake.handle("IsConversationParticipant", func(args []driver.Value) ([][]any, error) {
+		_, ok := d.lastRead[[2]string{args[0].(string), args[1].(string)}]
+		return one(ok), nil
+	})
+	fake.handle("GetOtherParticipantID", func(args []driver.Value) ([][]any, error) {
+		if otherID := d.otherParticipant(args[0].(string), args[1].(string)); otherID != "" {
+			return one(uuid.MustParse(otherID)), nil
+		}
+		return nil, nil
+	})
+	fake.handle("GetConversationForUser", func(args []driver.Value) ([][]any, error) {
+		for _, conversation := range d.conversations {
+			if conversation.ID.String() == args[0].(string) {
+				return [][]any{d.conversationRow(conversation, args[1].(string))}, nil
+			}
+		}
+		return nil, nil
+	})
+	fake.handle("ListConversations", func(args []driver.Value) ([][]any, error) {
+		conversations := slices.Clone(d.conversations)
+		slices.SortFunc(conversations, func(a, b *database.Conversation) int {
+			return b.UpdatedAt.Compare(a.UpdatedAt)
+		})
+		var rows [][]any
+		for _, conversation := range conversations {
+			if _, ok := d.lastRead[[2]string{conversation.ID.String(), args[0].(string)}]; ok {
+				rows = append(rows, d.conversationRow(conversation, args[0].(string)))
+			}
+		}
+		return rows, nil
+	})
+	fake.handle("CreateMessage", func(args []driver.Value) ([][]any, error) {
+		d.now = d.now.Add(time.Second)
+		message := database.Message{
+			ID:             uuid.New(),
+			ConversationID: uuid.MustParse(args[0].(string)),
+			SenderID:       uuid.MustParse(args[1].(string)),
+			Body:           args[2].(string),
+			CreatedAt:      d.now,
+		}
+		d.messages = append(d.messages, message)
+		return one(message), nil
+	})
+	fake.handle("TouchConversation", func(args []driver.Value) ([][]any, error) {
+		for _, conversation := range d.conversations {
+			if conversation.ID.String() == args[0].(string) {
+				conversation.UpdatedAt = d.now
+			}
+		}
+		return affected(1), nil
+	})
+	fake.handle("ListMessages", func(args []driver.Value) ([][]any, error) {
+		var rows [][]any
+		for _, message := range slices.Backward(d.messages) {
+			if message.ConversationID.String() != args[0].(string) {
+				continue
+			}
+			if cursor, ok := args[1].(time.Time); ok && !message.CreatedAt.Before(cursor) {
+				continue
+			}
+			if int64(len(rows)) < args[3].(int64) {
+				rows = append(rows, []any{message})
+			}
+		}
+		return rows, nil
+	})
+	fake.handle("MarkConversationRead", func(args []driver.Value) ([][]any, error) {
+		readAt := args[0].(time.Time)
+		key := [2]string{args[1].(string), args[2].(string)}
+		if last := d.lastRead[key]; last == nil || readAt.After(*last) {
+			d.lastRead[key] = &readAt
+		}
+		return affected(1), nil
+	})
+}
+
+func (d *conversationDB) otherParticipant(conversationID, userID string) string {
+	for key := range d.lastRead {
+		if key[0] == conversationID && key[1] != userID {
+			return key[1]
+		}
+	}
+	return ""
+}
+
+// conversationRow is a conversation as seen by userID, with its unread
+// count.
+func (d *conversationDB) conversationRow(conversation *database.Conversation, userID string) []any {
+	lastRead := d.lastRead[[2]string{conversation.ID.String(), userID}]
+	var unread int64
+	for _, message := range d.messages {
+		if message.ConversationID == conversation.ID && message.SenderID.String() != userID &&
+			(lastRead == nil || message.CreatedAt.After(*lastRead)) {
+			unread++
+		}
+	}
+	other := d.users[d.otherParticipant(conversation.ID.String(), userID)]
+	return []any{*conversation, other, unread}
+}
+
+func TestConversations(t *testing.T) {
+	alice, bob, carol := newTestUser("alice"), newTestUser("bob"), newTestUser("carol")
+	state := newConversationDB(alice, bob, carol)
+	state.blocks[[2]string{carol.ID.String(), alice.ID.String()}] = true
+	fake, conn := newFakeDB(t)
+	state.register(fake)
+
+	keys := newTestKeys(t)
+	handler := NewConversationHandler(database.New(conn), conn, log.New(io.Discard, "", 0), keys)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/conversations", handler.CreateConversation)
+	mux.HandleFunc("GET /api/conversations", handler.ListConversations)
+	mux.HandleFunc("GET /api/conversations/{conversationID}/messages", handler.ListMessages)
+	mux.HandleFunc("POST /api/conversations/{conversationID}/messages", handler.CreateMessage)
+
+	unreadCount := func(t *testing.T, userID uuid.UUID) int64 {
+		t.Helper()
+		rec := apiCall(t, mux, keys, userID, http.MethodGet, "/api/conversations", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+		conversations := decodeResponse[[]ConversationResponse](t, rec)
+		if len(conversations) != 1 {
+			t.Fatalf("Expected one conversation, got %d", len(conversations))
+		}
+		return conversations[0].UnreadCount
+	}
+
+	var conversationID uuid.UUID
+	var messagesURL string
+
+	t.Run("One conversation per pair", func(t *testing.T) {
+		rec := apiCall(t, mux, keys, alice.ID, http.MethodPost, "/api/conversations", createConversationDto{bob.ID})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d", rec.Code)
+		}
+		conversation := decodeResponse[ConversationResponse](t, rec)
+		if conversation.Participant.ID != bob.ID {
+			t.Fatalf("Expected bob as the participant, got %v", conversation.Participant.ID)
+		}
+		conversationID = conversation.ID
+		messagesURL = "/api/conversations/" + conversationID.String() + "/messages"
+
+		rec = apiCall(t, mux, keys, bob.ID, http.MethodPost, "/api/conversations", createConversationDto{alice.ID})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+		if id := decodeResponse[ConversationResponse](t, rec).ID; id != conversationID {
+			t.Fatalf("Expected the existing conversation %v, got %v", conversationID, id)
+		}
+	})
+
+	t.Run("Blocked users can't start a conversation", func(t *testing.T) {
+		rec := apiCall(t, mux, keys, alice.ID, http.MethodPost, "/api/conversations", createConversationDto{carol.ID})
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("Expected 403, got %d", rec.Code)
+		}
+	})
+
+	t.Run("Only participants can read or send", func(t *testing.T) {
+		if rec := apiCall(t, mux, keys, carol.ID, http.MethodGet, messagesURL, nil); rec.Code != http.StatusNotFound {
+			t.Fatalf("Expected 404 when reading, got %d", rec.Code)
+		}
+		rec := apiCall(t, mux, keys, carol.ID, http.MethodPost, messagesURL, createMessageDto{"Hi"})
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("Expected 404 when sending, got %d", rec.Code)
+		}
+	})
+
+	t.Run("Messages from the other participant are unread", func(t *testing.T) {
+		for _, body := range []string{"one", "two", "three"} {
+			if rec := apiCall(t, mux, keys, bob.ID, http.MethodPost, messagesURL, createMessageDto{body}); rec.Code != http.StatusCreated {
+				t.Fatalf("Expected 201, got %d", rec.Code)
+			}
+		}
+		if unread := unreadCount(t, alice.ID); unread != 3 {
+			t.Fatalf("Expected 3 unread messages for alice, got %d", unread)
+		}
+		if unread := unreadCount(t, bob.ID); unread != 0 {
+			t.Fatalf("Expected no unread messages for bob, got %d", unread)
+		}
+	})
+
+	t.Run("Reading marks up to the newest message returned", func(t *testing.T) {
+		rec := apiCall(t, mux, keys, alice.ID, http.MethodGet, messagesURL+"?limit=2", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+		if messages := decodeResponse[[]MessageResponse](t, rec); len(messages) != 2 || messages[0].Body != "three" {
+			t.Fatalf("Expected the two newest messages, got %v", messages)
+		}
+		if unread := unreadCount(t, alice.ID); unread != 0 {
+			t.Fatalf("Expected no unread messages after reading, got %d", unread)
+		}
+		next := rec.Header().Get("Link")
+		if next == "" {
+			t.Fatalf("Expected a Link to the next page")
+		}
+
+		if rec := apiCall(t, mux, keys, bob.ID, http.MethodPost, messagesURL, createMessageDto{"four"}); rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d", rec.Code)
+		}
+		nextURL, _, _ := strings.Cut(strings.TrimPrefix(next, "<"), ">")
+		if rec := apiCall(t, mux, keys, alice.ID, http.MethodGet, nextURL, nil); rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+		if unread := unreadCount(t, alice.ID); unread != 1 {
+			t.Fatalf("Expected an older page to leave the new message unread, got %d unread", unread)
+		}
+	})
+
+	t.Run("Blocking stops messages", func(t *testing.T) {
+		state.blocks[[2]string{alice.ID.String(), bob.ID.String()}] = true
+		rec := apiCall(t, mux, keys, bob.ID, http.MethodPost, messagesURL, createMessageDto{"five"})
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("Expected 403, got %d", rec.Code)
+		}
+	})
+}
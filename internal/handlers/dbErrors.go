@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+const (
+	pqForeignKeyViolation = "23503"
+	pqUniqueViolation     = "23505"
+)
+
+func isPqError(err error, code pq.ErrorCode) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == code
+}
+
+func isUniqueViolation(err error) bool {
+	return isPqError(err, pqUniqueViolation)
+}
+
+func isForeignKeyViolation(err error) bool {
+	return isPqError(err, pqForeignKeyViolation)
+}
+
+// violatedConstraint returns the name of the constraint or index a database
+// error reports, if any.
+func violatedConstraint(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Constraint
+	}
+	return ""
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+)
+
+// fakeDB stands in for Postgres in handler tests. Each query is answered by
+// the function registered under its sqlc name, from the arguments as
+// database/sql converts them: uuid.UUID arrives as a string, integers as
+// int64. Transactions are accepted but nothing is rolled back.
+type fakeDB struct {
+	t       *testing.T
+	mu      sync.Mutex
+	queries map[string]fakeQuery
+}
+
+// fakeQuery returns the rows of a query, each row being the values of its
+// columns in order. Structs, such as the models a row embeds, are spread
+// over their fields. For statements that return nothing, the number of rows
+// is the number of rows affected.
+type fakeQuery func(args []driver.Value) ([][]any, error)
+
+func newFakeDB(t *testing.T) (*fakeDB, *sql.DB) {
+	f := &fakeDB{t: t, queries: map[string]fakeQuery{}}
+	conn := sql.OpenDB(f)
+	t.Cleanup(func() { conn.Close() })
+	return f, conn
+}
+
+func (f *fakeDB) handle(name string, query fakeQuery) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queries[name] = query
+}
+
+func (f *fakeDB) run(query string, args []driver.Value) ([][]driver.Value, error) {
+	name, _, _ := strings.Cut(strings.TrimPrefix(query, "-- name: "), " ")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	answer, ok := f.queries[name]
+	if !ok {
+		f.t.Errorf("Unexpected query %s", name)
+		return nil, fmt.Errorf("fakeDB: no answer for %s", name)
+	}
+	rows, err := answer(args)
+	if err != nil {
+		return nil, err
+	}
+	values := make([][]driver.Value, len(rows))
+	for i, row := range rows {
+		for _, column := range row {
+			values[i] = appendDriverValues(values[i], reflect.ValueOf(column))
+		}
+	}
+	return values, nil
+}
+
+func appendDriverValues(values []driver.Value, v reflect.Value) []driver.Value {
+	if !v.IsValid() {
+		return append(values, nil)
+	}
+	if valuer, ok := v.Interface().(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if err != nil {
+			panic(err)
+		}
+		return append(values, value)
+	}
+	if _, ok := v.Interface().(time.Time); !ok && v.Kind() == reflect.Struct {
+		for i := range v.NumField() {
+			values = appendDriverValues(values, v.Field(i))
+		}
+		return values
+	}
+	value, err := driver.DefaultParameterConverter.ConvertValue(v.Interface())
+	if err != nil {
+		panic(err)
+	}
+	return append(values, value)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.db, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+type fakeResult int64
+
+func (r fakeResult) LastInsertId() (int64, error) { return 0, errors.New("not supported") }
+func (r fakeResult) RowsAffected() (int64, error) { return int64(r), nil }
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	rows, err := s.db.run(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return fakeResult(len(rows)), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, err := s.db.run(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{rows: rows}, nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+// Columns only gives the number of columns: sqlc scans by position.
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	if len(dest) != len(r.rows[0]) {
+		return errors.New("fakeDB: rows of different lengths")
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// affected returns n empty rows, for statements reporting n rows affected.
+func affected(n int) [][]any {
+	return make([][]any, n)
+}
+
+// one returns a single row.
+func one(columns ...any) [][]any {
+	return [][]any{columns}
+}
+
+// usersByID indexes users the way handleUsers looks them up.
+func usersByID(users ...database.User) map[string]database.User {
+	byID := make(map[string]database.User, len(users))
+	for _, user := range users {
+		byID[user.ID.String()] = user
+	}
+	return byID
+}
+
+// handleUsers answers GetUserByID from users, which a test may change as it
+// goes.
+func handleUsers(fake *fakeDB, users map[string]database.User) {
+	fake.handle("GetUserByID", func(args []driver.Value) ([][]any, error) {
+		if user, ok := users[args[0].(string)]; ok {
+			return one(user), nil
+		}
+		return nil, nil
+	})
+}
+
+// handleBlocks answers IsBlockedBetween from blocks, keyed by blocker then
+// blocked user.
+func handleBlocks(fake *fakeDB, blocks map[[2]string]bool) {
+	fake.handle("IsBlockedBetween", func(args []driver.Value) ([][]any, error) {
+		a, b := args[0].(string), args[1].(string)
+		return one(blocks[[2]string{a, b}] || blocks[[2]string{b, a}]), nil
+	})
+}
+
+// handleEmpty answers the named queries with no rows, for the side effects
+// and lookups a test doesn't look at.
+func handleEmpty(fake *fakeDB, names ...string) {
+	for _, name := range names {
+		fake.handle(name, func(args []driver.Value) ([][]any, error) {
+			return nil, nil
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+	"github.com/sheltonFr/bootdev/chirspy/internal/entities"
+	"github.com/sheltonFr/bootdev/chirspy/internal/feeds"
+	"github.com/sheltonFr/bootdev/chirspy/internal/utils"
+)
+
+const (
+	feedLength        = 50
+	feedTitleLength   = 60
+	feedFormatAtom    = ".atom"
+	feedFormatRSS     = ".rss"
+	feedFileParameter = "file"
+)
+
+type feedHandler struct {
+	db      *database.Queries
+	logger  *log.Logger
+	baseURL string
+}
+
+func NewFeedHandler(db *database.Queries, logger *log.Logger, baseURL string) *feedHandler {
+	return &feedHandler{db, logger, strings.TrimSuffix(baseURL, "/")}
+}
+
+// GlobalFeed serves the latest chirps of everyone as /feeds/chirps.atom or
+// /feeds/chirps.rss.
+func (f *feedHandler) GlobalFeed(w http.ResponseWriter, r *http.Request) {
+	name, format, ok := parseFeedFile(w, r)
+	if !ok {
+		return
+	}
+	if name != "chirps" {
+		utils.RespondWithError(w, http.StatusNotFound, "Feed not found")
+		return
+	}
+
+	chirps, err := f.db.ListChirpsDesc(r.Context(), database.ListChirpsDescParams{Limit: feedLength})
+	if err != nil {
+		f.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not build feed")
+		return
+	}
+	f.serveFeed(w, r, format, "Chirpy", f.baseURL+"/api/chirps", chirps, database.GetLastChirpChangeParams{})
+}
+
+// UserFeed serves the latest chirps of one user as
+// /feeds/users/{userID}.atom or .rss.
+func (f *feedHandler) UserFeed(w http.ResponseWriter, r *http.Request) {
+	name, format, ok := parseFeedFile(w, r)
+	if !ok {
+		return
+	}
+	userID, err := uuid.Parse(name)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Feed not found")
+		return
+	}
+
+	user, err := f.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, http.StatusNotFound, "Feed not found")
+			return
+		}
+		f.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not build feed")
+		return
+	}
+
+	chirps, err := f.db.ListChirpsDesc(r.Context(), database.ListChirpsDescParams{
+		AuthorID: uuid.NullUUID{UUID: user.ID, Valid: true},
+		Limit:    feedLength,
+	})
+	if err != nil {
+		f.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not build feed")
+		return
+	}
+	title := "Chirps by " + authorName(user)
+	f.serveFeed(w, r, format, title, f.baseURL+"/api/users/"+user.ID.String(), chirps, database.GetLastChirpChangeParams{
+		AuthorID: uuid.NullUUID{UUID: user.ID, Valid: true},
+	})
+}
+
+// TagFeed serves the latest chirps with a hashtag as /feeds/tags/{tag}.atom
+// or .rss.
+func (f *feedHandler) TagFeed(w http.ResponseWriter, r *http.Request) {
+	name, format, ok := parseFeedFile(w, r)
+	if !ok {
+		return
+	}
+	tag := entities.NormalizeTag(name)
+	if tag == "" {
+		utils.RespondWithError(w, http.StatusNotFound, "Feed not found")
+		return
+	}
+
+	chirps, err := f.db.ListChirpsByTag(r.Context(), database.ListChirpsByTagParams{
+		Tag:   tag,
+		Limit: feedLength,
+	})
+	if err != nil {
+		f.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not build feed")
+		return
+	}
+	f.serveFeed(w, r, format, "Chirps tagged #"+tag, f.baseURL+"/api/tags/"+tag+"/chirps", chirps, database.GetLastChirpChangeParams{
+		Tag: sql.NullString{String: tag, Valid: true},
+	})
+}
+
+// serveFeed renders chirps in the requested format. The feed is marked as
+// last modified when any chirp matching scope last changed, so deleting a
+// chirp counts too, and its ETag is a hash of the document;
+// http.ServeContent uses both to answer conditional requests with 304 Not
+// Modified.
+func (f *feedHandler) serveFeed(w http.ResponseWriter, r *http.Request, format, title, link string, chirps []database.Chirp, scope database.GetLastChirpChangeParams) {
+	feed, err := f.buildFeed(r.Context(), title, link, f.baseURL+r.URL.Path, chirps)
+	if err != nil {
+		f.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not build feed")
+		return
+	}
+	lastChanged, err := f.db.GetLastChirpChange(r.Context(), scope)
+	if err != nil {
+		f.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not build feed")
+		return
+	}
+	if lastChanged.Valid && lastChanged.Time.After(feed.Updated) {
+		feed.Updated = lastChanged.Time
+	}
+
+	var body []byte
+	if format == feedFormatAtom {
+		body, err = feeds.Atom(feed)
+		w.Header().Set("Content-Type", feeds.ContentTypeAtom)
+	} else {
+		body, err = feeds.RSS(feed)
+		w.Header().Set("Content-Type", feeds.ContentTypeRSS)
+	}
+	if err != nil {
+		f.logger.Printf("Feed rendering error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not build feed")
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	http.ServeContent(w, r, "", feed.Updated, bytes.NewReader(body))
+}
+
+func (f *feedHandler) buildFeed(ctx context.Context, title, link, selfLink string, chirps []database.Chirp) (feeds.Feed, error) {
+	feed := feeds.Feed{
+		Title:    title,
+		ID:       selfLink,
+		Link:     link,
+		SelfLink: selfLink,
+	}
+
+	authorIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		authorIDs = append(authorIDs, chirp.UserID)
+	}
+	users, err := f.db.GetUsersByIDs(ctx, authorIDs)
+	if err != nil {
+		return feeds.Feed{}, err
+	}
+	authors := make(map[uuid.UUID]string, len(users))
+	for _, user := range users {
+		authors[user.ID] = authorName(user)
+	}
+
+	for _, chirp := range chirps {
+		chirpURL := f.baseURL + "/api/chirps/" + chirp.ID.String()
+		content := chirp.Body
+		if chirp.Kind == chirpKindRechirp && chirp.ReferencedChirpID.Valid {
+			content = "Rechirped " + f.baseURL + "/api/chirps/" + chirp.ReferencedChirpID.UUID.String()
+		}
+		feed.Entries = append(feed.Entries, feeds.Entry{
+			ID:        chirpURL,
+			Title:     feedEntryTitle(content),
+			Link:      chirpURL,
+			Content:   content,
+			Author:    authors[chirp.UserID],
+			Published: chirp.CreatedAt,
+			Updated:   chirp.UpdatedAt,
+		})
+		if chirp.UpdatedAt.After(feed.Updated) {
+			feed.Updated = chirp.UpdatedAt
+		}
+	}
+	return feed, nil
+}
+
+// parseFeedFile splits the last path segment of a feed URL into its name and
+// format, answering with 404 when the extension isn't .atom or .rss.
+func parseFeedFile(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	file := r.PathValue(feedFileParameter)
+	for _, format := range []string{feedFormatAtom, feedFormatRSS} {
+		if name, ok := strings.CutSuffix(file, format); ok && name != "" {
+			return name, format, true
+		}
+	}
+	utils.RespondWithError(w, http.StatusNotFound, "Feed not found")
+	return "", "", false
+}
+
+func authorName(user database.User) string {
+	if user.Username.Valid {
+		return "@" + user.Username.String
+	}
+	return user.ID.String()
+}
+
+// feedEntryTitle shortens a chirp body to a single line usable as a title.
+func feedEntryTitle(body string) string {
+	title := strings.Join(strings.Fields(body), " ")
+	if utf8.RuneCountInString(title) <= feedTitleLength {
+		return title
+	}
+	return fmt.Sprintf("%s…", string([]rune(title)[:feedTitleLength-1]))
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sheltonFr/bootdev/chirspy/internal/auth"
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+	"github.com/sheltonFr/bootdev/chirspy/internal/mappers"
+	"github.com/sheltonFr/bootdev/chirspy/internal/notifications"
+	"github.com/sheltonFr/bootdev/chirspy/internal/pagination"
+	"github.com/sheltonFr/bootdev/chirspy/internal/utils"
+)
+
+type followHandler struct {
+	db       *database.Queries
+	logger   *log.Logger
+	keys     *auth.KeySet
+	notifier *notifications.Notifier
+}
+
+func NewFollowHandler(db *database.Queries, logger *log.Logger, keys *auth.KeySet, notifier *notifications.Notifier) *followHandler {
+	return &followHandler{db, logger, keys, notifier}
+}
+
+type FollowResponse struct {
+	User       mappers.PublicUserResponse `json:"user"`
+	FollowedAt time.Time                  `json:"followed_at"`
+}
+
+// Follow makes the caller follow the user in the path. Following someone
+// twice is not an error.
+func (f *followHandler) Follow(w http.ResponseWriter, r *http.Request) {
+	followerID, ok := authenticateRequest(w, r, f.keys)
+	if !ok {
+		return
+	}
+	followeeID, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+	if followerID == followeeID {
+		utils.RespondWithError(w, http.StatusBadRequest, "You cannot follow yourself")
+		return
+	}
+
+	blocked, err := f.db.IsBlockedBetween(r.Context(), database.IsBlockedBetweenParams{
+		UserA: followerID,
+		UserB: followeeID,
+	})
+	if err != nil {
+		f.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not follow user")
+		return
+	}
+	if blocked {
+		utils.RespondWithError(w, http.StatusForbidden, "You can't follow this user")
+		return
+	}
+
+	rows, err := f.db.CreateFollow(r.Context(), database.CreateFollowParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			utils.RespondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		f.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not follow user")
+		return
+	}
+	if rows > 0 {
+		f.notifier.Notify(r.Context(), notifications.KindFollow, followeeID, followerID, uuid.NullUUID{}, "")
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *followHandler) Unfollow(w http.ResponseWriter, r *http.Request) {
+	followerID, ok := authenticateRequest(w, r, f.keys)
+	if !ok {
+		return
+	}
+	followeeID, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := f.db.DeleteFollow(r.Context(), database.DeleteFollowParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		f.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not unfollow user")
+		return
+	}
+	if rows == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "You are not following this user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListFollowers lists who follows the user in the path, most recent first.
+func (f *followHandler) ListFollowers(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+	page, ok := parseDescPage(w, r, "Followers can only be sorted desc")
+	if !ok {
+		return
+	}
+
+	cursorCreatedAt, cursorID := page.CursorArgs()
+	rows, err := f.db.ListFollowers(r.Context(), database.ListFollowersParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		Limit:           page.Limit + 1,
+	})
+	if err != nil {
+		f.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch followers")
+		return
+	}
+
+	follows := make([]FollowResponse, len(rows))
+	for i, row := range rows {
+		follows[i] = FollowResponse{mappers.MapPublicUser(&row.User), row.FollowedAt}
+	}
+	respondWithFollowPage(w, r, follows, page)
+}
+
+// ListFollowing lists who the user in the path follows, most recent first.
+func (f *followHandler) ListFollowing(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+	page, ok := parseDescPage(w, r, "Followed users can only be sorted desc")
+	if !ok {
+		return
+	}
+
+	cursorCreatedAt, cursorID := page.CursorArgs()
+	rows, err := f.db.ListFollowing(r.Context(), database.ListFollowingParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		Limit:           page.Limit + 1,
+	})
+	if err != nil {
+		f.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch followed users")
+		return
+	}
+
+	follows := make([]FollowResponse, len(rows))
+	for i, row := range rows {
+		follows[i] = FollowResponse{mappers.MapPublicUser(&row.User), row.FollowedAt}
+	}
+	respondWithFollowPage(w, r, follows, page)
+}
+
+func respondWithFollowPage(w http.ResponseWriter, r *http.Request, follows []FollowResponse, page pagination.Params) {
+	follows, hasMore := pagination.Trim(follows, page)
+	if hasMore {
+		last := follows[len(follows)-1]
+		w.Header().Set("Link", pagination.NextLink(r.URL, pagination.Cursor{CreatedAt: last.FollowedAt, ID: last.User.ID}))
+	}
+	utils.RespondWithJSON(w, http.StatusOK, follows)
+}
+
+func parseUserID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid UUID format")
+		return uuid.Nil, false
+	}
+	return id, true
+}
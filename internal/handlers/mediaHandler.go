@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/sheltonFr/bootdev/chirspy/internal/auth"
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+	"github.com/sheltonFr/bootdev/chirspy/internal/media"
+	"github.com/sheltonFr/bootdev/chirspy/internal/utils"
+)
+
+const (
+	maxUploadBytes = 10 << 20
+	mediaFormField = "file"
+)
+
+type mediaHandler struct {
+	db      *database.Queries
+	logger  *log.Logger
+	keys    *auth.KeySet
+	storage media.Storage
+}
+
+func NewMediaHandler(db *database.Queries, logger *log.Logger, keys *auth.KeySet, storage media.Storage) *mediaHandler {
+	return &mediaHandler{db, logger, keys, storage}
+}
+
+type MediaResponse struct {
+	ID           uuid.UUID `json:"id"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	ContentType  string    `json:"content_type"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+	AltText      string    `json:"alt_text"`
+}
+
+func mapMedia(storage media.Storage, medium database.Medium, altText string) MediaResponse {
+	return MediaResponse{
+		ID:           medium.ID,
+		URL:          storage.URL(medium.StorageKey),
+		ThumbnailURL: storage.URL(medium.ThumbnailKey),
+		ContentType:  medium.ContentType,
+		Width:        medium.Width,
+		Height:       medium.Height,
+		AltText:      altText,
+	}
+}
+
+// UploadMedia accepts a PNG, JPEG or GIF in the "file" field of a multipart
+// form. The image is re-encoded without its metadata and stored along with a
+// thumbnail; the returned ID can then be attached to a chirp.
+func (m *mediaHandler) UploadMedia(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticateRequest(w, r, m.keys)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	file, _, err := r.FormFile(mediaFormField)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			utils.RespondWithError(w, http.StatusRequestEntityTooLarge, "File is too large")
+			return
+		}
+		utils.RespondWithError(w, http.StatusBadRequest, "Expected a multipart form with a file field")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Could not read file")
+		return
+	}
+
+	processed, err := media.Process(data)
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrUnsupportedType):
+			utils.RespondWithError(w, http.StatusUnsupportedMediaType, "Only PNG, JPEG and GIF images are supported")
+		case errors.Is(err, media.ErrImageTooLarge):
+			utils.RespondWithError(w, http.StatusRequestEntityTooLarge, "Image dimensions are too large")
+		default:
+			m.logger.Printf("Image processing error: %v\n", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not process image")
+		}
+		return
+	}
+
+	id := uuid.New()
+	storageKey := id.String() + processed.Extension
+	thumbnailKey := id.String() + "_thumb" + processed.ThumbnailExtension
+	if err := m.storage.Save(r.Context(), storageKey, processed.Data, processed.ContentType); err != nil {
+		m.logger.Printf("Storage error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not store image")
+		return
+	}
+	if err := m.storage.Save(r.Context(), thumbnailKey, processed.Thumbnail, processed.ThumbnailType); err != nil {
+		m.logger.Printf("Storage error: %v\n", err)
+		m.deleteFiles(r, storageKey)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not store image")
+		return
+	}
+
+	medium, err := m.db.CreateMedia(r.Context(), database.CreateMediaParams{
+		ID:           id,
+		UserID:       userID,
+		ContentType:  processed.ContentType,
+		Width:        int32(processed.Width),
+		Height:       int32(processed.Height),
+		SizeBytes:    int32(len(processed.Data)),
+		StorageKey:   storageKey,
+		ThumbnailKey: thumbnailKey,
+	})
+	if err != nil {
+		m.logger.Printf("DB error: %v\n", err)
+		m.deleteFiles(r, storageKey, thumbnailKey)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not store image")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, mapMedia(m.storage, medium, ""))
+}
+
+// deleteFiles removes files stored for an upload that couldn't be completed.
+func (m *mediaHandler) deleteFiles(r *http.Request, keys ...string) {
+	for _, key := range keys {
+		if err := m.storage.Delete(r.Context(), key); err != nil {
+			m.logger.Printf("Storage error: %v\n", err)
+		}
+	}
+}
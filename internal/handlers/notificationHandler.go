@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sheltonFr/bootdev/chirspy/internal/auth"
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+	"github.com/sheltonFr/bootdev/chirspy/internal/notifications"
+	"github.com/sheltonFr/bootdev/chirspy/internal/utils"
+)
+
+const (
+	notificationWriteWait   = 10 * time.Second
+	notificationPongWait    = 60 * time.Second
+	notificationPingPeriod  = notificationPongWait * 9 / 10
+	notificationBacklogPage = 100
+	maxClientMessageBytes   = 512
+	// notificationResumeOverlap is how far before a resume cursor
+	// notifications are sent again, to cover those that committed after a
+	// notification with a higher id.
+	notificationResumeOverlap = 10 * time.Second
+	// notificationSentMemory is how long a connection remembers the
+	// notifications it sent, to skip them when the broker delivers them too.
+	notificationSentMemory = time.Minute
+)
+
+var notificationUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Sockets are authenticated with an explicit token rather than cookies,
+	// so a foreign page can't open one on a user's behalf.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+type notificationHandler struct {
+	db     *database.Queries
+	logger *log.Logger
+	keys   *auth.KeySet
+	broker *notifications.Broker
+}
+
+func NewNotificationHandler(db *database.Queries, logger *log.Logger, keys *auth.KeySet, broker *notifications.Broker) *notificationHandler {
+	return &notificationHandler{db, logger, keys, broker}
+}
+
+// Connect upgrades the request to a WebSocket streaming the caller's
+// notifications as JSON messages. The access token comes from the
+// Authorization header or, for browsers that can't set it, the token query
+// parameter. Clients resume after a disconnect by passing the id of the last
+// notification they received as after; everything newer is sent before the
+// live notifications. Ids are assigned before notifications commit, so the
+// ones created shortly before the cursor are sent again as well: clients
+// should skip ids they already have.
+//
+// The server pings every notificationPingPeriod and drops connections that
+// don't answer. Connections that fall behind are closed with status 1013
+// (try again later) and should reconnect with their cursor.
+func (n *notificationHandler) Connect(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		token = r.URL.Query().Get("token")
+	}
+	userID, err := n.keys.ValidateJWT(token)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var cursor int64
+	if after := r.URL.Query().Get("after"); after != "" {
+		cursor, err = strconv.ParseInt(after, 10, 64)
+		if err != nil || cursor < 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid after cursor")
+			return
+		}
+	}
+
+	conn, err := notificationUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already answered the request.
+		return
+	}
+	defer conn.Close()
+
+	// Subscribe before reading the backlog so nothing created in between is
+	// lost; notifications sent from the backlog are skipped when the broker
+	// delivers them as well.
+	sub := n.broker.Subscribe(userID)
+	defer sub.Cancel()
+
+	// Clients aren't expected to send anything, but reading is what processes
+	// pongs and close frames.
+	conn.SetReadLimit(maxClientMessageBytes)
+	conn.SetReadDeadline(time.Now().Add(notificationPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(notificationPongWait))
+	})
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	sent := map[int64]time.Time{}
+	send := func(notification notifications.Notification) bool {
+		conn.SetWriteDeadline(time.Now().Add(notificationWriteWait))
+		if err := conn.WriteJSON(notification); err != nil {
+			return false
+		}
+		sent[notification.ID] = time.Now()
+		cursor = max(cursor, notification.ID)
+		return true
+	}
+	closeWith := func(code int, reason string) {
+		message := websocket.FormatCloseMessage(code, reason)
+		conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(notificationWriteWait))
+	}
+
+	if cursor > 0 {
+		overlap, err := n.db.ListNotificationsNear(r.Context(), database.ListNotificationsNearParams{
+			UserID:         userID,
+			CursorID:       cursor,
+			OverlapSeconds: notificationResumeOverlap.Seconds(),
+			Limit:          notificationBacklogPage,
+		})
+		if err != nil {
+			n.logger.Printf("DB error: %v\n", err)
+			closeWith(websocket.CloseInternalServerErr, "Could not load notifications")
+			return
+		}
+		for _, notification := range overlap {
+			if !send(notifications.Map(notification)) {
+				return
+			}
+		}
+	}
+	for {
+		backlog, err := n.db.ListNotificationsAfter(r.Context(), database.ListNotificationsAfterParams{
+			UserID:  userID,
+			AfterID: cursor,
+			Limit:   notificationBacklogPage,
+		})
+		if err != nil {
+			n.logger.Printf("DB error: %v\n", err)
+			closeWith(websocket.CloseInternalServerErr, "Could not load notifications")
+			return
+		}
+		for _, notification := range backlog {
+			if !send(notifications.Map(notification)) {
+				return
+			}
+		}
+		if len(backlog) < notificationBacklogPage {
+			break
+		}
+	}
+
+	ping := time.NewTicker(notificationPingPeriod)
+	defer ping.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case notification, ok := <-sub.Notifications():
+			if !ok {
+				closeWith(websocket.CloseTryAgainLater, "Too far behind, reconnect with your cursor")
+				return
+			}
+			if _, ok := sent[notification.ID]; ok {
+				continue
+			}
+			if !send(notification) {
+				return
+			}
+		case <-ping.C:
+			for id, at := range sent {
+				if time.Since(at) > notificationSentMemory {
+					delete(sent, id)
+				}
+			}
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(notificationWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/sheltonFr/bootdev/chirspy/internal/pagination"
+	"github.com/sheltonFr/bootdev/chirspy/internal/utils"
+)
+
+// parseDescPage reads the pagination parameters of a listing that is only
+// available newest first, answering ascending requests with ascMsg.
+func parseDescPage(w http.ResponseWriter, r *http.Request, ascMsg string) (pagination.Params, bool) {
+	page, err := pagination.ParseParams(r.URL.Query(), false)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return pagination.Params{}, false
+	}
+	if page.Ascending {
+		utils.RespondWithError(w, http.StatusBadRequest, ascMsg)
+		return pagination.Params{}, false
+	}
+	return page, true
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/sheltonFr/bootdev/chirspy/internal/auth"
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+	"github.com/sheltonFr/bootdev/chirspy/internal/utils"
+	"github.com/sheltonFr/bootdev/chirspy/internal/webhooks"
+)
+
+const polkaEventUserUpgraded = "user.upgraded"
+
+type polkaHandler struct {
+	db       *database.Queries
+	conn     *sql.DB
+	logger   *log.Logger
+	polkaKey string
+	webhooks *webhooks.Dispatcher
+}
+
+func NewPolkaHandler(db *database.Queries, conn *sql.DB, logger *log.Logger, polkaKey string, dispatcher *webhooks.Dispatcher) *polkaHandler {
+	return &polkaHandler{db, conn, logger, polkaKey, dispatcher}
+}
+
+type polkaWebhookDto struct {
+	Event string `json:"event"`
+	Data  struct {
+		UserID uuid.UUID `json:"user_id"`
+	} `json:"data"`
+}
+
+// WebhookHandler receives payment events from Polka. Events other than
+// user.upgraded are acknowledged and ignored; upgrading an already upgraded
+// user is a no-op, so redelivered events are safe.
+func (p *polkaHandler) WebhookHandler(w http.ResponseWriter, r *http.Request) {
+	key, err := auth.GetAPIKey(r.Header)
+	if err != nil || p.polkaKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(p.polkaKey)) != 1 {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var webhookDto polkaWebhookDto
+	err = json.NewDecoder(r.Body).Decode(&webhookDto)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if webhookDto.Event != polkaEventUserUpgraded {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	err = runInTx(r.Context(), p.conn, p.db, func(q *database.Queries) error {
+		rows, err := q.UpgradeUserToChirpyRed(r.Context(), webhookDto.Data.UserID)
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+		return p.webhooks.Enqueue(r.Context(), q, webhooks.EventUserUpgraded, webhookDto.Data)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		p.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not upgrade user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+	"github.com/sheltonFr/bootdev/chirspy/internal/webhooks"
+)
+
+// sendPolkaEvent plays the part of the payment provider.
+func sendPolkaEvent(t *testing.T, h http.HandlerFunc, apiKey, event string, userID uuid.UUID) int {
+	t.Helper()
+	payload := map[string]any{"event": event, "data": map[string]string{"user_id": userID.String()}}
+	body, _ := json.Marshal(payload)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/polka/webhooks", bytes.NewReader(body))
+	if apiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+apiKey)
+	}
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	return rec.Code
+}
+
+func TestPolkaWebhook(t *testing.T) {
+	const key = "polka-test-key"
+	userID := uuid.New()
+	upgraded := map[string]bool{userID.String(): false}
+	var events []string
+	fake, conn := newFakeDB(t)
+	fake.handle("UpgradeUserToChirpyRed", func(args []driver.Value) ([][]any, error) {
+		if _, ok := upgraded[args[0].(string)]; !ok {
+			return nil, nil
+		}
+		upgraded[args[0].(string)] = true
+		return affected(1), nil
+	})
+	fake.handle("EnqueueWebhookDeliveries", func(args []driver.Value) ([][]any, error) {
+		events = append(events, args[0].(string))
+		return nil, nil
+	})
+	db := database.New(conn)
+	logger := log.New(io.Discard, "", 0)
+	handler := NewPolkaHandler(db, conn, logger, key, webhooks.NewDispatcher(db, logger))
+
+	t.Run("Missing or wrong key", func(t *testing.T) {
+		for _, k := range []string{"", "wrong-key"} {
+			if code := sendPolkaEvent(t, handler.WebhookHandler, k, polkaEventUserUpgraded, userID); code != http.StatusUnauthorized {
+				t.Fatalf("Expected 401 for key %q, got %d", k, code)
+			}
+		}
+	})
+
+	t.Run("Ignored event", func(t *testing.T) {
+		if code := sendPolkaEvent(t, handler.WebhookHandler, key, "user.payment_failed", userID); code != http.StatusNoContent {
+			t.Fatalf("Expected 204, got %d", code)
+		}
+		if upgraded[userID.String()] {
+			t.Fatalf("User should not have been upgraded")
+		}
+	})
+
+	t.Run("Upgrade is idempotent", func(t *testing.T) {
+		for range 2 {
+			if code := sendPolkaEvent(t, handler.WebhookHandler, key, polkaEventUserUpgraded, userID); code != http.StatusNoContent {
+				t.Fatalf("Expected 204, got %d", code)
+			}
+		}
+		if !upgraded[userID.String()] {
+			t.Fatalf("Expected user to be upgraded")
+		}
+		if len(events) != 2 || events[0] != webhooks.EventUserUpgraded {
+			t.Fatalf("Expected a %s webhook event per upgrade, got %v", webhooks.EventUserUpgraded, events)
+		}
+	})
+
+	t.Run("Unknown user", func(t *testing.T) {
+		if code := sendPolkaEvent(t, handler.WebhookHandler, key, polkaEventUserUpgraded, uuid.New()); code != http.StatusNotFound {
+			t.Fatalf("Expected 404, got %d", code)
+		}
+	})
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/sheltonFr/bootdev/chirspy/internal/auth"
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+	"github.com/sheltonFr/bootdev/chirspy/internal/notifications"
+	"github.com/sheltonFr/bootdev/chirspy/internal/utils"
+)
+
+// maxEmojiRunes leaves room for ZWJ sequences such as family emojis.
+const maxEmojiRunes = 10
+
+type reactionHandler struct {
+	db       *database.Queries
+	logger   *log.Logger
+	keys     *auth.KeySet
+	notifier *notifications.Notifier
+}
+
+func NewReactionHandler(db *database.Queries, logger *log.Logger, keys *auth.KeySet, notifier *notifications.Notifier) *reactionHandler {
+	return &reactionHandler{db, logger, keys, notifier}
+}
+
+// PutReaction adds the caller's reaction with the emoji in the path. Reacting
+// twice with the same emoji is not an error.
+func (h *reactionHandler) PutReaction(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticateRequest(w, r, h.keys)
+	if !ok {
+		return
+	}
+	chirpID, ok := parseChirpID(w, r)
+	if !ok {
+		return
+	}
+	emoji := r.PathValue("emoji")
+	if !isEmoji(emoji) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Reaction must be a single emoji")
+		return
+	}
+
+	chirp, err := h.db.GetChirpyByID(r.Context(), chirpID)
+	if err != nil || chirp.DeletedAt.Valid {
+		if err == nil || errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, http.StatusNotFound, "Chirp Not Found")
+			return
+		}
+		h.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not add reaction")
+		return
+	}
+
+	rows, err := h.db.CreateReaction(r.Context(), database.CreateReactionParams{
+		ChirpID: chirp.ID,
+		UserID:  userID,
+		Emoji:   emoji,
+	})
+	if err != nil {
+		h.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not add reaction")
+		return
+	}
+	if rows > 0 {
+		h.notifier.Notify(r.Context(), notifications.KindReaction, chirp.UserID, userID, uuid.NullUUID{UUID: chirp.ID, Valid: true}, emoji)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *reactionHandler) DeleteReaction(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticateRequest(w, r, h.keys)
+	if !ok {
+		return
+	}
+	chirpID, ok := parseChirpID(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := h.db.DeleteReaction(r.Context(), database.DeleteReactionParams{
+		ChirpID: chirpID,
+		UserID:  userID,
+		Emoji:   r.PathValue("emoji"),
+	})
+	if err != nil {
+		h.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not remove reaction")
+		return
+	}
+	if rows == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "Reaction not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// isEmoji reports whether s is a single emoji: a keycap, a flag, or a symbol
+// optionally followed by a variation selector, skin tone modifiers or tags,
+// and joined with zero width joiners to further symbols.
+func isEmoji(s string) bool {
+	if utf8.RuneCountInString(s) > maxEmojiRunes {
+		return false
+	}
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return false
+	}
+
+	switch first := runes[0]; {
+	case strings.ContainsRune("0123456789#*", first):
+		rest := string(runes[1:])
+		return rest == "\u20e3" || rest == "\ufe0f\u20e3"
+	case isRegionalIndicator(first):
+		return len(runes) == 2 && isRegionalIndicator(runes[1])
+	case !unicode.Is(unicode.So, first):
+		return false
+	}
+
+	for i := 1; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case r == '\u200d':
+			i++
+			if i == len(runes) || !unicode.Is(unicode.So, runes[i]) {
+				return false
+			}
+		case r == '\ufe0f':
+		case r >= 0x1f3fb && r <= 0x1f3ff:
+		case r >= 0xe0020 && r <= 0xe007f:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isRegionalIndicator reports whether r is one of the letters flags are
+// spelled with.
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
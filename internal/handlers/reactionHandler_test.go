@@ -0,0 +1,19 @@
+package handlers
+
+import "testing"
+
+func TestIsEmoji(t *testing.T) {
+	valid := []string{"👍", "❤️", "🔥", "👍🏽", "👩‍👩‍👧", "🇲🇿", "1️⃣", "#⃣", "🏴󠁧󠁢󠁳󠁣󠁴󠁿", "🧑🏽‍💻"}
+	for _, s := range valid {
+		if !isEmoji(s) {
+			t.Fatalf("Expected %q to be accepted", s)
+		}
+	}
+
+	invalid := []string{"", "a", "lol", "👍a", "1", "\u200d", "🔥🔥🔥🔥🔥🔥🔥🔥🔥🔥🔥", "🔥🔥🔥", "👍👎", "🇲🇿🇵🇹", "🇲", "1️⃣2️⃣", "1️", "👩‍", "👩‍a", "🔥\u20e3"}
+	for _, s := range invalid {
+		if isEmoji(s) {
+			t.Fatalf("Expected %q to be rejected", s)
+		}
+	}
+}
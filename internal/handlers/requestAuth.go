@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/sheltonFr/bootdev/chirspy/internal/auth"
+	"github.com/sheltonFr/bootdev/chirspy/internal/utils"
+)
+
+// authenticateRequest validates the bearer token of the request and returns
+// the caller's user ID. It writes a 401 response and returns false on failure.
+func authenticateRequest(w http.ResponseWriter, r *http.Request, keys *auth.KeySet) (uuid.UUID, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return uuid.Nil, false
+	}
+	userID, err := keys.ValidateJWT(token)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// optionalUserID returns the caller's user ID when the request carries a valid
+// bearer token. Anonymous requests and invalid tokens yield a null ID.
+func optionalUserID(r *http.Request, keys *auth.KeySet) uuid.NullUUID {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	userID, err := keys.ValidateJWT(token)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: userID, Valid: true}
+}
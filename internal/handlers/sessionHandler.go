@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/sheltonFr/bootdev/chirspy/internal/auth"
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+	"github.com/sheltonFr/bootdev/chirspy/internal/utils"
+)
+
+const maxUserAgentLength = 255
+
+type sessionHandler struct {
+	db     *database.Queries
+	logger *log.Logger
+	keys   *auth.KeySet
+}
+
+func NewSessionHandler(db *database.Queries, logger *log.Logger, keys *auth.KeySet) *sessionHandler {
+	return &sessionHandler{db, logger, keys}
+}
+
+// SessionResponse describes one login. A session is a refresh token family,
+// so its ID stays the same across refresh token rotations.
+type SessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IpAddress  string    `json:"ip_address"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+func (s *sessionHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticateRequest(w, r, s.keys)
+	if !ok {
+		return
+	}
+
+	sessions, err := s.db.ListSessionsForUser(r.Context(), userID)
+	if err != nil {
+		s.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch sessions")
+		return
+	}
+
+	response := make([]SessionResponse, len(sessions))
+	for i, session := range sessions {
+		response[i] = SessionResponse{
+			ID:         session.FamilyID,
+			UserAgent:  session.UserAgent,
+			IpAddress:  session.IpAddress,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+		}
+	}
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+func (s *sessionHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticateRequest(w, r, s.keys)
+	if !ok {
+		return
+	}
+
+	sessionID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	rows, err := s.db.RevokeSessionForUser(r.Context(), database.RevokeSessionForUserParams{
+		FamilyID: sessionID,
+		UserID:   userID,
+	})
+	if err != nil {
+		s.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not revoke session")
+		return
+	}
+	if rows == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *sessionHandler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticateRequest(w, r, s.keys)
+	if !ok {
+		return
+	}
+
+	err := s.db.RevokeAllRefreshTokensForUser(r.Context(), userID)
+	if err != nil {
+		s.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not revoke sessions")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// userAgent returns the request's user agent as valid UTF-8, cut to at most
+// maxUserAgentLength bytes without splitting a character.
+func userAgent(r *http.Request) string {
+	ua := strings.ToValidUTF8(r.UserAgent(), "")
+	if len(ua) > maxUserAgentLength {
+		n := maxUserAgentLength
+		for n > 0 && !utf8.RuneStart(ua[n]) {
+			n--
+		}
+		ua = ua[:n]
+	}
+	return ua
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestUserAgent(t *testing.T) {
+	tests := map[string]string{
+		"curl/8.5.0": "curl/8.5.0",
+		strings.Repeat("a", maxUserAgentLength+10):      strings.Repeat("a", maxUserAgentLength),
+		strings.Repeat("a", maxUserAgentLength-1) + "é": strings.Repeat("a", maxUserAgentLength-1),
+		strings.Repeat("a", maxUserAgentLength-2) + "😀": strings.Repeat("a", maxUserAgentLength-2),
+		"bad\xffbyte": "badbyte",
+	}
+	for input, expected := range tests {
+		r := httptest.NewRequest("GET", "/api/login", nil)
+		r.Header.Set("User-Agent", input)
+		got := userAgent(r)
+		if got != expected {
+			t.Errorf("userAgent(%q) = %q, expected %q", input, got, expected)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("userAgent(%q) returned invalid UTF-8", input)
+		}
+	}
+}
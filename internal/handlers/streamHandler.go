@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sheltonFr/bootdev/chirspy/internal/entities"
+	"github.com/sheltonFr/bootdev/chirspy/internal/stream"
+	"github.com/sheltonFr/bootdev/chirspy/internal/utils"
+)
+
+const (
+	streamHeartbeatInterval = 15 * time.Second
+	streamRetryMillis       = 3000
+	streamWriteTimeout      = 10 * time.Second
+)
+
+type streamHandler struct {
+	hub    *stream.Hub
+	logger *log.Logger
+}
+
+func NewStreamHandler(hub *stream.Hub, logger *log.Logger) *streamHandler {
+	return &streamHandler{hub, logger}
+}
+
+// StreamChirps pushes new chirps as Server-Sent Events, and a delete event
+// carrying the chirp's ID when one of them is deleted. author_id and tag
+// restrict the stream to one author or one hashtag. Each event carries an
+// id; clients reconnecting with a Last-Event-ID header (or last_event_id
+// query parameter) first receive the recent chirps they missed, leaving out
+// those deleted since. A comment line is sent periodically so idle
+// connections stay open.
+func (s *streamHandler) StreamChirps(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	authorID := uuid.NullUUID{}
+	if authorIDString := query.Get("author_id"); authorIDString != "" {
+		id, err := uuid.Parse(authorIDString)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid author_id")
+			return
+		}
+		authorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	tag := entities.NormalizeTag(query.Get("tag"))
+
+	lastEventIDString := r.Header.Get("Last-Event-ID")
+	if lastEventIDString == "" {
+		lastEventIDString = query.Get("last_event_id")
+	}
+	var lastEventID uint64
+	if lastEventIDString != "" {
+		var err error
+		lastEventID, err = strconv.ParseUint(lastEventIDString, 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid Last-Event-ID")
+			return
+		}
+	}
+
+	matches := func(event stream.Event) bool {
+		if authorID.Valid && event.AuthorID != authorID.UUID {
+			return false
+		}
+		return tag == "" || slices.Contains(event.Tags, tag)
+	}
+
+	rc := http.NewResponseController(w)
+	sub, missed := s.hub.Subscribe(lastEventID)
+	defer sub.Cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Keep reverse proxies such as nginx from buffering the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// write sends one chunk and flushes it, giving up on clients that stop
+	// reading.
+	write := func(chunk string) bool {
+		rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if _, err := fmt.Fprint(w, chunk); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	if !write(fmt.Sprintf("retry: %d\n\n", streamRetryMillis)) {
+		return
+	}
+	for _, event := range missed {
+		if matches(event) && !write(formatEvent(event)) {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if !write(": heartbeat\n\n") {
+				return
+			}
+		case event, ok := <-sub.Events():
+			if !ok {
+				if sub.Dropped() {
+					s.logger.Printf("Dropped slow stream client %s\n", clientIP(r))
+				}
+				return
+			}
+			if matches(event) && !write(formatEvent(event)) {
+				return
+			}
+		}
+	}
+}
+
+// formatEvent renders an event in the text/event-stream format. Data is
+// compact JSON, so it fits on a single data line.
+func formatEvent(event stream.Event) string {
+	return fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+}
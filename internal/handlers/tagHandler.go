@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+	"github.com/sheltonFr/bootdev/chirspy/internal/utils"
+)
+
+const (
+	trendingWindow   = 24 * time.Hour
+	trendingHalfLife = 6 * time.Hour
+	trendingLimit    = 20
+)
+
+type TrendingTag struct {
+	Tag        string  `json:"tag"`
+	Score      float64 `json:"score"`
+	ChirpCount int64   `json:"chirp_count"`
+}
+
+type TrendingResponse struct {
+	Tags       []TrendingTag `json:"tags"`
+	ComputedAt time.Time     `json:"computed_at"`
+}
+
+// tagHandler serves trending hashtags from an in-memory snapshot, so the
+// aggregation query runs once per refresh instead of once per request.
+type tagHandler struct {
+	db     *database.Queries
+	logger *log.Logger
+
+	mu       sync.RWMutex
+	trending TrendingResponse
+}
+
+func NewTagHandler(db *database.Queries, logger *log.Logger) *tagHandler {
+	return &tagHandler{db: db, logger: logger}
+}
+
+func (t *tagHandler) GetTrending(w http.ResponseWriter, r *http.Request) {
+	t.mu.RLock()
+	trending := t.trending
+	t.mu.RUnlock()
+
+	if trending.ComputedAt.IsZero() {
+		if err := t.RefreshTrending(r.Context()); err != nil {
+			t.logger.Printf("DB error: %v\n", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not fetch trending tags")
+			return
+		}
+		t.mu.RLock()
+		trending = t.trending
+		t.mu.RUnlock()
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, trending)
+}
+
+// RefreshTrending recomputes the trending tags and swaps in the new snapshot.
+func (t *tagHandler) RefreshTrending(ctx context.Context) error {
+	rows, err := t.db.GetTrendingTags(ctx, database.GetTrendingTagsParams{
+		HalfLifeSeconds: trendingHalfLife.Seconds(),
+		WindowSeconds:   trendingWindow.Seconds(),
+		Limit:           trendingLimit,
+	})
+	if err != nil {
+		return err
+	}
+
+	tags := make([]TrendingTag, 0, len(rows))
+	for _, row := range rows {
+		tags = append(tags, TrendingTag{Tag: row.Name, Score: row.Score, ChirpCount: row.ChirpCount})
+	}
+
+	t.mu.Lock()
+	t.trending = TrendingResponse{Tags: tags, ComputedAt: time.Now().UTC()}
+	t.mu.Unlock()
+	return nil
+}
+
+// RunTrendingRefresher refreshes the trending tags every interval until ctx
+// is done. Failed refreshes are logged and the previous snapshot is kept.
+func (t *tagHandler) RunTrendingRefresher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.RefreshTrending(ctx); err != nil && ctx.Err() == nil {
+			t.logger.Printf("Trending refresh failed: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
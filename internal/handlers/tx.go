@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+)
+
+// runInTx runs fn with queries bound to a new transaction, committing it when
+// fn succeeds and rolling it back otherwise.
+func runInTx(ctx context.Context, conn *sql.DB, db *database.Queries, fn func(*database.Queries) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(db.WithTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -1,28 +1,74 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/sheltonFr/bootdev/chirspy/internal/auth"
 	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+	"github.com/sheltonFr/bootdev/chirspy/internal/entities"
 	"github.com/sheltonFr/bootdev/chirspy/internal/mappers"
 	"github.com/sheltonFr/bootdev/chirspy/internal/utils"
+	"github.com/sheltonFr/bootdev/chirspy/internal/webhooks"
 )
 
 type userHandler struct {
-	db     *database.Queries
-	logger *log.Logger
+	db       *database.Queries
+	conn     *sql.DB
+	logger   *log.Logger
+	keys     *auth.KeySet
+	webhooks *webhooks.Dispatcher
 }
 
-func NewUserHandler(db *database.Queries, logger *log.Logger) *userHandler {
-	return &userHandler{db, logger}
+func NewUserHandler(db *database.Queries, conn *sql.DB, logger *log.Logger, keys *auth.KeySet, dispatcher *webhooks.Dispatcher) *userHandler {
+	return &userHandler{db, conn, logger, keys, dispatcher}
 }
 
+const (
+	defaultUserSearchLimit = 10
+	maxUserSearchLimit     = 25
+
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+	maxLocationLength    = 30
+	maxProfileURLLength  = 500
+
+	// usernameIndex is the unique index that keeps usernames unique regardless
+	// of case.
+	usernameIndex      = "users_username_lower_idx"
+	invalidUsernameMsg = "Username must be 3 to 20 letters, digits or underscores"
+)
+
 type createUserDto struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	Username string `json:"username"`
+}
+
+type updateUserDto struct {
+	Email           string `json:"email"`
+	Password        string `json:"password"`
+	CurrentPassword string `json:"current_password"`
+	Username        string `json:"username"`
+}
+
+// updateProfileDto holds the profile fields to change. Fields left out are
+// kept as they are; an empty string clears a field.
+type updateProfileDto struct {
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	AvatarURL   *string `json:"avatar_url"`
+	Location    *string `json:"location"`
+	Website     *string `json:"website"`
 }
 
 func (u *userHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
@@ -32,6 +78,10 @@ func (u *userHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		utils.RespondWithError(w, http.StatusBadRequest, "Could not parse Json")
 		return
 	}
+	if userDto.Username != "" && !entities.IsValidUsername(userDto.Username) {
+		utils.RespondWithError(w, http.StatusBadRequest, invalidUsernameMsg)
+		return
+	}
 
 	passwordHash, err := auth.HashPassword(userDto.Password)
 	if err != nil {
@@ -43,15 +93,299 @@ func (u *userHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	userParams := database.CreateUserParams{
 		Email:          userDto.Email,
 		HashedPassword: passwordHash,
+		Username:       sql.NullString{String: userDto.Username, Valid: userDto.Username != ""},
 	}
 
-	user, err := u.db.CreateUser(r.Context(), userParams)
-
+	var user database.User
+	err = runInTx(r.Context(), u.conn, u.db, func(q *database.Queries) error {
+		user, err = q.CreateUser(r.Context(), userParams)
+		if err != nil {
+			return err
+		}
+		return u.webhooks.Enqueue(r.Context(), q, webhooks.EventUserCreated, mappers.MapUser(&user))
+	})
 	if err != nil {
+		if isUniqueViolation(err) {
+			respondWithUserConflict(w, err)
+			return
+		}
 		u.logger.Printf("DB error: %v\n", err)
 		utils.RespondWithError(w, http.StatusInternalServerError, "Could not create user")
 		return
 	}
 	utils.RespondWithJSON(w, http.StatusCreated, mappers.MapUser(&user))
+}
+
+// UpdateUser changes the caller's email, username and/or password. A password
+// change requires the current password and revokes every refresh token of the
+// user.
+func (u *userHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticateRequest(w, r, u.keys)
+	if !ok {
+		return
+	}
+
+	var userDto updateUserDto
+	err := json.NewDecoder(r.Body).Decode(&userDto)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Could not parse Json")
+		return
+	}
+	if userDto.Email == "" && userDto.Password == "" && userDto.Username == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Nothing to update")
+		return
+	}
+	if userDto.Username != "" && !entities.IsValidUsername(userDto.Username) {
+		utils.RespondWithError(w, http.StatusBadRequest, invalidUsernameMsg)
+		return
+	}
+
+	user, err := u.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		u.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not update user")
+		return
+	}
+
+	userParams := database.UpdateUserParams{
+		ID:             user.ID,
+		Email:          user.Email,
+		HashedPassword: user.HashedPassword,
+		Username:       user.Username,
+	}
+	if userDto.Email != "" {
+		userParams.Email = userDto.Email
+	}
+	if userDto.Username != "" {
+		userParams.Username = sql.NullString{String: userDto.Username, Valid: true}
+	}
+
+	passwordChanged := userDto.Password != ""
+	if passwordChanged {
+		match, _ := auth.CheckPasswordHash(userDto.CurrentPassword, user.HashedPassword)
+		if !match {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Current password is incorrect")
+			return
+		}
+
+		userParams.HashedPassword, err = auth.HashPassword(userDto.Password)
+		if err != nil {
+			u.logger.Printf("Hashing error: %v\n", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error hashing password")
+			return
+		}
+	}
+
+	// The password change and the revocation of the old sessions commit
+	// together, so a failed revocation can't leave them signed in.
+	var updated database.User
+	err = runInTx(r.Context(), u.conn, u.db, func(q *database.Queries) error {
+		updated, err = q.UpdateUser(r.Context(), userParams)
+		if err != nil {
+			return err
+		}
+		if passwordChanged {
+			if err := q.RevokeAllRefreshTokensForUser(r.Context(), user.ID); err != nil {
+				return err
+			}
+		}
+		return u.webhooks.Enqueue(r.Context(), q, webhooks.EventUserUpdated, mappers.MapUser(&updated))
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			respondWithUserConflict(w, err)
+			return
+		}
+		u.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not update user")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, mappers.MapUser(&updated))
+}
+
+// GetProfile returns the public profile of a user, with the number of chirps,
+// followers and followed accounts. It is available without authentication.
+func (u *userHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	profile, err := u.db.GetUserProfile(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		u.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve profile")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, mappers.MapUserProfile(&profile))
+}
 
+// UpdateProfile changes the caller's display name, bio, avatar, location
+// and/or website and returns the updated profile.
+func (u *userHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticateRequest(w, r, u.keys)
+	if !ok {
+		return
+	}
+
+	var profileDto updateProfileDto
+	if err := json.NewDecoder(r.Body).Decode(&profileDto); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Could not parse Json")
+		return
+	}
+
+	user, err := u.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		u.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not update profile")
+		return
+	}
+
+	profileParams := database.UpdateUserProfileParams{
+		ID:          user.ID,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarUrl:   user.AvatarUrl,
+		Location:    user.Location,
+		Website:     user.Website,
+	}
+	fields := []struct {
+		name     string
+		value    *string
+		dst      *string
+		maxRunes int
+		isURL    bool
+	}{
+		{"display_name", profileDto.DisplayName, &profileParams.DisplayName, maxDisplayNameLength, false},
+		{"bio", profileDto.Bio, &profileParams.Bio, maxBioLength, false},
+		{"avatar_url", profileDto.AvatarURL, &profileParams.AvatarUrl, maxProfileURLLength, true},
+		{"location", profileDto.Location, &profileParams.Location, maxLocationLength, false},
+		{"website", profileDto.Website, &profileParams.Website, maxProfileURLLength, true},
+	}
+	for _, field := range fields {
+		if field.value == nil {
+			continue
+		}
+		value := strings.TrimSpace(*field.value)
+		if err := validateProfileField(field.name, value, field.maxRunes, field.isURL); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		*field.dst = value
+	}
+
+	err = runInTx(r.Context(), u.conn, u.db, func(q *database.Queries) error {
+		updated, err := q.UpdateUserProfile(r.Context(), profileParams)
+		if err != nil {
+			return err
+		}
+		return u.webhooks.Enqueue(r.Context(), q, webhooks.EventUserUpdated, mappers.MapUser(&updated))
+	})
+	if err != nil {
+		u.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not update profile")
+		return
+	}
+
+	profile, err := u.db.GetUserProfile(r.Context(), user.ID)
+	if err != nil {
+		u.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve profile")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, mappers.MapUserProfile(&profile))
+}
+
+// validateProfileField checks the length of a profile field and, for links,
+// that it is an absolute http(s) URL. Empty values are always accepted.
+func validateProfileField(name, value string, maxRunes int, isURL bool) error {
+	if value == "" {
+		return nil
+	}
+	if utf8.RuneCountInString(value) > maxRunes {
+		return fmt.Errorf("%s must be at most %d characters", name, maxRunes)
+	}
+	if strings.ContainsFunc(value, unicode.IsControl) {
+		return fmt.Errorf("%s contains invalid characters", name)
+	}
+	if isURL {
+		parsed, err := url.Parse(value)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("%s must be an http or https URL", name)
+		}
+	}
+	return nil
+}
+
+// SearchUsers powers people pickers: it returns the users whose username
+// starts with q, best matches first. Emails are neither searched nor
+// returned, so the endpoint can't tell who owns an address.
+func (u *userHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authenticateRequest(w, r, u.keys); !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	q := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(query.Get("q")), "@"))
+	if q == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+
+	limit := defaultUserSearchLimit
+	if rawLimit := query.Get("limit"); rawLimit != "" {
+		n, err := strconv.Atoi(rawLimit)
+		if err != nil || n < 1 {
+			utils.RespondWithError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = min(n, maxUserSearchLimit)
+	}
+
+	users, err := u.db.SearchUsers(r.Context(), database.SearchUsersParams{
+		Pattern: escapeLike(q) + "%",
+		Query:   q,
+		Limit:   int32(limit),
+	})
+	if err != nil {
+		u.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not search users")
+		return
+	}
+
+	response := make([]mappers.PublicUserResponse, len(users))
+	for i := range users {
+		response[i] = mappers.MapPublicUser(&users[i])
+	}
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// escapeLike escapes the LIKE wildcards in s so it only matches literally.
+// Underscores are common in usernames and would otherwise match any
+// character.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// respondWithUserConflict tells the client whether the email or the username
+// of a user is already taken.
+func respondWithUserConflict(w http.ResponseWriter, err error) {
+	if violatedConstraint(err) == usernameIndex {
+		utils.RespondWithError(w, http.StatusConflict, "Username is already taken")
+		return
+	}
+	utils.RespondWithError(w, http.StatusConflict, "Email is already in use")
 }
@@ -0,0 +1,40 @@
+package handlers
+
+import "testing"
+
+func TestEscapeLike(t *testing.T) {
+	tests := map[string]string{
+		"bob":       "bob",
+		"bob_smith": `bob\_smith`,
+		"100%":      `100\%`,
+		`a\b`:       `a\\b`,
+	}
+	for input, expected := range tests {
+		if got := escapeLike(input); got != expected {
+			t.Errorf("escapeLike(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+func TestValidateProfileField(t *testing.T) {
+	tests := []struct {
+		value    string
+		maxRunes int
+		isURL    bool
+		valid    bool
+	}{
+		{"", 5, true, true},
+		{"Zoé", 3, false, true},
+		{"Zoé!", 3, false, false},
+		{"line\nbreak", 50, false, false},
+		{"https://chirpy.dev/me", 50, true, true},
+		{"javascript:alert(1)", 50, true, false},
+		{"chirpy.dev", 50, true, false},
+	}
+	for _, test := range tests {
+		err := validateProfileField("field", test.value, test.maxRunes, test.isURL)
+		if (err == nil) != test.valid {
+			t.Errorf("validateProfileField(%q) returned %v, expected valid=%v", test.value, err, test.valid)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+	"github.com/sheltonFr/bootdev/chirspy/internal/pagination"
+	"github.com/sheltonFr/bootdev/chirspy/internal/utils"
+	"github.com/sheltonFr/bootdev/chirspy/internal/webhooks"
+)
+
+const (
+	deliveryStatusPending   = "pending"
+	deliveryStatusDelivered = "delivered"
+	deliveryStatusFailed    = "failed"
+)
+
+// webhookHandler manages webhook subscriptions. Its routes are for admins
+// only, which main enforces.
+type webhookHandler struct {
+	db     *database.Queries
+	logger *log.Logger
+}
+
+func NewWebhookHandler(db *database.Queries, logger *log.Logger) *webhookHandler {
+	return &webhookHandler{db, logger}
+}
+
+type createWebhookDto struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+// WebhookResponse describes a subscription. The secret used to sign payloads
+// is only returned when the webhook is created.
+type WebhookResponse struct {
+	ID        uuid.UUID `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DeliveryResponse is one entry of a webhook's delivery log. ResponseStatus
+// is the HTTP status of the last attempt, missing when it got no response.
+type DeliveryResponse struct {
+	ID             int64      `json:"id"`
+	Event          string     `json:"event"`
+	Status         string     `json:"status"`
+	Attempts       int32      `json:"attempts"`
+	ResponseStatus *int32     `json:"response_status"`
+	LastError      string     `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func (h *webhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var dto createWebhookDto
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Could not parse Json")
+		return
+	}
+
+	target, err := url.Parse(dto.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "url must be an absolute http or https URL")
+		return
+	}
+	events, err := validateWebhookEvents(dto.Events)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		h.logger.Printf("Error generating webhook secret: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not create webhook")
+		return
+	}
+	webhook, err := h.db.CreateWebhook(r.Context(), database.CreateWebhookParams{
+		Url:    target.String(),
+		Events: events,
+		Secret: secret,
+	})
+	if err != nil {
+		h.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not create webhook")
+		return
+	}
+
+	response := mapWebhook(webhook)
+	response.Secret = webhook.Secret
+	utils.RespondWithJSON(w, http.StatusCreated, response)
+}
+
+func (h *webhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	dbWebhooks, err := h.db.ListWebhooks(r.Context())
+	if err != nil {
+		h.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve webhooks")
+		return
+	}
+
+	response := make([]WebhookResponse, len(dbWebhooks))
+	for i, webhook := range dbWebhooks {
+		response[i] = mapWebhook(webhook)
+	}
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// DeleteWebhook removes a subscription along with its pending deliveries and
+// delivery log.
+func (h *webhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseWebhookID(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := h.db.DeleteWebhook(r.Context(), id)
+	if err != nil {
+		h.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not delete webhook")
+		return
+	}
+	if rows == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeliveries returns the delivery log of a webhook, newest first. Pages
+// are requested with limit and before, the ID of the last delivery seen, and
+// advertised through a Link header.
+func (h *webhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseWebhookID(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	limit := pagination.DefaultLimit
+	if rawLimit := query.Get("limit"); rawLimit != "" {
+		n, err := strconv.Atoi(rawLimit)
+		if err != nil || n < 1 {
+			utils.RespondWithError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = min(n, pagination.MaxLimit)
+	}
+	var before sql.NullInt64
+	if rawBefore := query.Get("before"); rawBefore != "" {
+		n, err := strconv.ParseInt(rawBefore, 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "before must be a delivery ID")
+			return
+		}
+		before = sql.NullInt64{Int64: n, Valid: true}
+	}
+
+	if _, err := h.db.GetWebhook(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, http.StatusNotFound, "Webhook not found")
+			return
+		}
+		h.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve deliveries")
+		return
+	}
+
+	deliveries, err := h.db.ListWebhookDeliveries(r.Context(), database.ListWebhookDeliveriesParams{
+		WebhookID: id,
+		BeforeID:  before,
+		Limit:     int32(limit) + 1,
+	})
+	if err != nil {
+		h.logger.Printf("DB error: %v\n", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not retrieve deliveries")
+		return
+	}
+
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+		w.Header().Set("Link", pagination.NextBeforeLink(r.URL, deliveries[limit-1].ID))
+	}
+	response := make([]DeliveryResponse, len(deliveries))
+	for i, delivery := range deliveries {
+		response[i] = mapDelivery(delivery)
+	}
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// validateWebhookEvents checks an event filter and returns it without
+// duplicates.
+func validateWebhookEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return nil, errors.New("events must list at least one event")
+	}
+	var unique []string
+	for _, event := range events {
+		if !webhooks.IsValidEvent(event) {
+			return nil, fmt.Errorf("unknown event %q, must be one of %s", event, strings.Join(webhooks.Events, ", "))
+		}
+		if !slices.Contains(unique, event) {
+			unique = append(unique, event)
+		}
+	}
+	return unique, nil
+}
+
+func parseWebhookID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid UUID format")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func mapWebhook(webhook database.Webhook) WebhookResponse {
+	return WebhookResponse{
+		ID:        webhook.ID,
+		URL:       webhook.Url,
+		Events:    webhook.Events,
+		CreatedAt: webhook.CreatedAt,
+		UpdatedAt: webhook.UpdatedAt,
+	}
+}
+
+func mapDelivery(delivery database.WebhookDelivery) DeliveryResponse {
+	response := DeliveryResponse{
+		ID:        delivery.ID,
+		Event:     delivery.Event,
+		Status:    deliveryStatusPending,
+		Attempts:  delivery.Attempts,
+		LastError: delivery.LastError,
+		CreatedAt: delivery.CreatedAt,
+	}
+	if delivery.ResponseStatus.Valid {
+		response.ResponseStatus = &delivery.ResponseStatus.Int32
+	}
+	switch {
+	case delivery.DeliveredAt.Valid:
+		response.Status = deliveryStatusDelivered
+		response.DeliveredAt = &delivery.DeliveredAt.Time
+	case delivery.FailedAt.Valid:
+		response.Status = deliveryStatusFailed
+	default:
+		response.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	return response
+}
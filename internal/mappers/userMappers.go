@@ -8,10 +8,35 @@ import (
 )
 
 type UserResponse struct {
-	ID        uuid.UUID `json:"id"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          uuid.UUID `json:"id"`
+	Email       string    `json:"email"`
+	Username    *string   `json:"username"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+	Role        string    `json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// PublicUserResponse is what other users get to see about an account. It
+// never includes the email address.
+type PublicUserResponse struct {
+	ID          uuid.UUID `json:"id"`
+	Username    *string   `json:"username"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   string    `json:"avatar_url"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// UserProfileResponse is the public profile page of a user.
+type UserProfileResponse struct {
+	PublicUserResponse
+	Bio            string `json:"bio"`
+	Location       string `json:"location"`
+	Website        string `json:"website"`
+	ChirpCount     int64  `json:"chirp_count"`
+	FollowerCount  int64  `json:"follower_count"`
+	FollowingCount int64  `json:"following_count"`
 }
 
 type UserLoginResponse struct {
@@ -24,19 +49,50 @@ func MapUserLogin(dbUser *database.User, acessToken, refreshToken string) UserLo
 	return UserLoginResponse{
 		Token:        acessToken,
 		RefreshToken: refreshToken,
-		UserResponse: UserResponse{ID: dbUser.ID,
-			Email:     dbUser.Email,
-			CreatedAt: dbUser.CreatedAt,
-			UpdatedAt: dbUser.UpdatedAt,
-		},
+		UserResponse: MapUser(dbUser),
 	}
 }
 
 func MapUser(dbUser *database.User) UserResponse {
 	return UserResponse{
-		ID:        dbUser.ID,
-		Email:     dbUser.Email,
-		CreatedAt: dbUser.CreatedAt,
-		UpdatedAt: dbUser.UpdatedAt,
+		ID:          dbUser.ID,
+		Email:       dbUser.Email,
+		Username:    username(dbUser),
+		IsChirpyRed: dbUser.IsChirpyRed,
+		Role:        dbUser.Role,
+		CreatedAt:   dbUser.CreatedAt,
+		UpdatedAt:   dbUser.UpdatedAt,
+	}
+}
+
+func MapPublicUser(dbUser *database.User) PublicUserResponse {
+	return PublicUserResponse{
+		ID:          dbUser.ID,
+		Username:    username(dbUser),
+		DisplayName: dbUser.DisplayName,
+		AvatarURL:   dbUser.AvatarUrl,
+		IsChirpyRed: dbUser.IsChirpyRed,
+		CreatedAt:   dbUser.CreatedAt,
+	}
+}
+
+func MapUserProfile(profile *database.GetUserProfileRow) UserProfileResponse {
+	return UserProfileResponse{
+		PublicUserResponse: MapPublicUser(&profile.User),
+		Bio:                profile.User.Bio,
+		Location:           profile.User.Location,
+		Website:            profile.User.Website,
+		ChirpCount:         profile.ChirpCount,
+		FollowerCount:      profile.FollowerCount,
+		FollowingCount:     profile.FollowingCount,
+	}
+}
+
+// username returns the user's username, or nil for accounts created before
+// usernames existed that haven't picked one yet.
+func username(dbUser *database.User) *string {
+	if !dbUser.Username.Valid {
+		return nil
 	}
+	return &dbUser.Username.String
 }
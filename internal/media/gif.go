@@ -0,0 +1,69 @@
+package media
+
+import "encoding/binary"
+
+// gifPixels adds up the width x height of every frame of a GIF by walking its
+// blocks, without decompressing any image data. It stops as soon as the total
+// goes over limit. ok is false when the block structure can't be read.
+func gifPixels(data []byte, limit int) (total int, ok bool) {
+	if len(data) < 13 {
+		return 0, false
+	}
+	pos := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		pos += 3 << (flags&0x07 + 1)
+	}
+
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x3B: // trailer
+			return total, true
+		case 0x21: // extension: label, then data sub-blocks
+			if pos+2 > len(data) {
+				return total, false
+			}
+			pos, ok = skipSubBlocks(data, pos+2)
+			if !ok {
+				return total, false
+			}
+		case 0x2C: // image descriptor
+			if pos+10 > len(data) {
+				return total, false
+			}
+			width := int(binary.LittleEndian.Uint16(data[pos+5:]))
+			height := int(binary.LittleEndian.Uint16(data[pos+7:]))
+			total += width * height
+			if total > limit {
+				return total, true
+			}
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << (flags&0x07 + 1)
+			}
+			// Skip the LZW minimum code size, then the image data.
+			pos, ok = skipSubBlocks(data, pos+1)
+			if !ok {
+				return total, false
+			}
+		default:
+			return total, false
+		}
+	}
+	// A missing trailer is left for the decoder to judge.
+	return total, true
+}
+
+// skipSubBlocks returns the position right after the sub-blocks starting at
+// pos, which end with an empty block.
+func skipSubBlocks(data []byte, pos int) (int, bool) {
+	for pos < len(data) {
+		size := int(data[pos])
+		pos++
+		if size == 0 {
+			return pos, true
+		}
+		pos += size
+	}
+	return pos, false
+}
@@ -0,0 +1,173 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	ContentTypePNG  = "image/png"
+	ContentTypeJPEG = "image/jpeg"
+	ContentTypeGIF  = "image/gif"
+
+	// MaxPixels bounds the decoded size of an upload, so a small file
+	// claiming huge dimensions can't exhaust memory.
+	MaxPixels     = 40_000_000
+	ThumbnailSize = 400
+	jpegQuality   = 90
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type")
+	ErrImageTooLarge   = errors.New("image dimensions are too large")
+)
+
+// Image is an upload that has been validated and re-encoded.
+type Image struct {
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+	Data        []byte
+	// Thumbnail fits in ThumbnailSize x ThumbnailSize. It is a JPEG for JPEG
+	// uploads and a PNG otherwise.
+	Thumbnail          []byte
+	ThumbnailType      string
+	ThumbnailExtension string
+}
+
+// Process checks that data is a PNG, JPEG or GIF by sniffing its content
+// rather than trusting the client, then re-encodes it. Re-encoding drops
+// every metadata block, EXIF included; the EXIF orientation of JPEGs is
+// applied to the pixels first so photos keep their rotation.
+func Process(data []byte) (*Image, error) {
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case ContentTypePNG, ContentTypeJPEG, ContentTypeGIF:
+	default:
+		return nil, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	processed := &Image{ContentType: contentType}
+	var frame image.Image
+	var out bytes.Buffer
+
+	switch contentType {
+	case ContentTypeGIF:
+		// GIFs are decoded frame by frame to keep animations. Every frame is
+		// held in memory, so their total size is checked before decoding.
+		pixels, ok := gifPixels(data, MaxPixels)
+		if !ok {
+			return nil, ErrUnsupportedType
+		}
+		if pixels > MaxPixels {
+			return nil, ErrImageTooLarge
+		}
+		animation, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrUnsupportedType
+		}
+		if err := gif.EncodeAll(&out, animation); err != nil {
+			return nil, err
+		}
+		frame = animation.Image[0]
+		processed.Extension = ".gif"
+	case ContentTypePNG:
+		frame, err = png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrUnsupportedType
+		}
+		if err := png.Encode(&out, frame); err != nil {
+			return nil, err
+		}
+		processed.Extension = ".png"
+	case ContentTypeJPEG:
+		frame, err = jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrUnsupportedType
+		}
+		frame = applyOrientation(frame, jpegOrientation(data))
+		if err := jpeg.Encode(&out, frame, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+		processed.Extension = ".jpg"
+	}
+
+	processed.Data = out.Bytes()
+	processed.Width = frame.Bounds().Dx()
+	processed.Height = frame.Bounds().Dy()
+
+	var thumb bytes.Buffer
+	small := Thumbnail(frame, ThumbnailSize)
+	if contentType == ContentTypeJPEG {
+		err = jpeg.Encode(&thumb, small, &jpeg.Options{Quality: jpegQuality})
+		processed.ThumbnailType, processed.ThumbnailExtension = ContentTypeJPEG, ".jpg"
+	} else {
+		err = png.Encode(&thumb, small)
+		processed.ThumbnailType, processed.ThumbnailExtension = ContentTypePNG, ".png"
+	}
+	if err != nil {
+		return nil, err
+	}
+	processed.Thumbnail = thumb.Bytes()
+
+	return processed, nil
+}
+
+// Thumbnail scales src down to fit in a maxSize x maxSize square, keeping its
+// aspect ratio. Each destination pixel is the average of the source pixels it
+// covers. Images that already fit are returned unchanged.
+func Thumbnail(src image.Image, maxSize int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSize && height <= maxSize {
+		return src
+	}
+
+	thumbWidth, thumbHeight := maxSize, maxSize
+	if width > height {
+		thumbHeight = max(1, height*maxSize/width)
+	} else {
+		thumbWidth = max(1, width*maxSize/height)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, thumbWidth, thumbHeight))
+	for y := 0; y < thumbHeight; y++ {
+		y0 := bounds.Min.Y + y*height/thumbHeight
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*height/thumbHeight)
+		for x := 0; x < thumbWidth; x++ {
+			x0 := bounds.Min.X + x*width/thumbWidth
+			x1 := max(x0+1, bounds.Min.X+(x+1)*width/thumbWidth)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
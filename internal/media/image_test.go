@@ -0,0 +1,140 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		img.Set(x, 0, color.RGBA{R: 255, A: 255})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// jpegWithOrientation encodes a JPEG and inserts an EXIF block carrying the
+// given orientation right after the SOI marker.
+func jpegWithOrientation(t *testing.T, width, height, orientation int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, exifOrientationTag)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3) // SHORT
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, uint16(orientation))
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	data := buf.Bytes()
+	return append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+}
+
+func TestProcessRejectsNonImages(t *testing.T) {
+	_, err := Process([]byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"))
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("Expected ErrUnsupportedType, got %v", err)
+	}
+}
+
+func TestProcessPNGThumbnail(t *testing.T) {
+	processed, err := Process(encodePNG(t, 800, 200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if processed.ContentType != ContentTypePNG || processed.Width != 800 || processed.Height != 200 {
+		t.Fatalf("Unexpected image %s %dx%d", processed.ContentType, processed.Width, processed.Height)
+	}
+
+	thumb, err := png.Decode(bytes.NewReader(processed.Thumbnail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := thumb.Bounds().Size(); size.X != ThumbnailSize || size.Y != ThumbnailSize/4 {
+		t.Fatalf("Unexpected thumbnail size %v", size)
+	}
+}
+
+func TestProcessJPEGStripsEXIF(t *testing.T) {
+	data := jpegWithOrientation(t, 40, 20, 6)
+	if jpegOrientation(data) != 6 {
+		t.Fatal("Test image has no orientation")
+	}
+
+	processed, err := Process(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(processed.Data, []byte("Exif")) {
+		t.Fatal("EXIF block was not stripped")
+	}
+	// Orientation 6 means the stored pixels must be rotated a quarter turn.
+	if processed.Width != 20 || processed.Height != 40 {
+		t.Fatalf("Expected a 20x40 image, got %dx%d", processed.Width, processed.Height)
+	}
+}
+
+func encodeGIF(t *testing.T, width, height, frames int) []byte {
+	t.Helper()
+	frame := image.NewPaletted(image.Rect(0, 0, width, height), color.Palette{color.Black, color.White})
+	animation := &gif.GIF{}
+	for range frames {
+		animation.Image = append(animation.Image, frame)
+		animation.Delay = append(animation.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, animation); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGIFPixels(t *testing.T) {
+	data := encodeGIF(t, 30, 20, 3)
+	if total, ok := gifPixels(data, MaxPixels); !ok || total != 3*30*20 {
+		t.Fatalf("Expected %d pixels, got %d (ok=%v)", 3*30*20, total, ok)
+	}
+	if total, ok := gifPixels(data, 1000); !ok || total != 2*30*20 {
+		t.Fatalf("Expected the walk to stop after 2 frames, got %d pixels (ok=%v)", total, ok)
+	}
+	if _, ok := gifPixels(data[:20], MaxPixels); ok {
+		t.Fatalf("Expected a truncated block to be rejected")
+	}
+}
+
+func TestProcessRejectsLargeAnimations(t *testing.T) {
+	processed, err := Process(encodeGIF(t, 40, 30, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if processed.ContentType != ContentTypeGIF || processed.Width != 40 || processed.Height != 30 {
+		t.Fatalf("Unexpected image %s %dx%d", processed.ContentType, processed.Width, processed.Height)
+	}
+
+	// Each frame is within MaxPixels, all of them together are not.
+	_, err = Process(encodeGIF(t, 2000, 2000, MaxPixels/(2000*2000)+1))
+	if !errors.Is(err, ErrImageTooLarge) {
+		t.Fatalf("Expected ErrImageTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package media
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir(), "/media/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := storage.Save(ctx, "a/b.png", []byte("data"), ContentTypePNG); err != nil {
+		t.Fatal(err)
+	}
+	file, err := storage.Open(ctx, "a/b.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(file)
+	file.Close()
+	if string(data) != "data" {
+		t.Fatalf("Unexpected content %q", data)
+	}
+	if url := storage.URL("a/b.png"); url != "/media/a/b.png" {
+		t.Fatalf("Unexpected URL %s", url)
+	}
+
+	if err := storage.Save(ctx, "../escape.png", []byte("data"), ContentTypePNG); err == nil {
+		t.Fatal("Expected keys outside the directory to be rejected")
+	}
+	if err := storage.Delete(ctx, "a/b.png"); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Delete(ctx, "a/b.png"); err != nil {
+		t.Fatal("Deleting a missing file should succeed")
+	}
+}
@@ -0,0 +1,84 @@
+package notifications
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Broker routes notifications to the live connections of their recipient.
+// It only reaches connections made to this process; clients catch up on
+// anything else from the database when they reconnect.
+//
+// Sending never blocks: a subscription whose buffer is full is closed and
+// the client is expected to reconnect and resume from its last cursor.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[uuid.UUID]map[*Subscription]struct{}
+	bufferSize  int
+}
+
+type Subscription struct {
+	broker        *Broker
+	userID        uuid.UUID
+	notifications chan Notification
+}
+
+func NewBroker(bufferSize int) *Broker {
+	return &Broker{
+		subscribers: map[uuid.UUID]map[*Subscription]struct{}{},
+		bufferSize:  bufferSize,
+	}
+}
+
+// Subscribe registers a connection of userID.
+func (b *Broker) Subscribe(userID uuid.UUID) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := &Subscription{broker: b, userID: userID, notifications: make(chan Notification, b.bufferSize)}
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = map[*Subscription]struct{}{}
+	}
+	b.subscribers[userID][sub] = struct{}{}
+	return sub
+}
+
+// Publish delivers a notification to every connection of its recipient.
+func (b *Broker) Publish(notification Notification) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subscribers[notification.UserID] {
+		select {
+		case sub.notifications <- notification:
+		default:
+			b.remove(sub)
+		}
+	}
+}
+
+// Notifications returns the channel notifications are delivered on. It is
+// closed when the subscription is cancelled or falls behind.
+func (s *Subscription) Notifications() <-chan Notification {
+	return s.notifications
+}
+
+// Cancel unregisters the subscription. It is safe to call more than once.
+func (s *Subscription) Cancel() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.remove(s)
+}
+
+func (b *Broker) remove(sub *Subscription) {
+	subs := b.subscribers[sub.userID]
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(b.subscribers, sub.userID)
+	}
+	close(sub.notifications)
+}
@@ -0,0 +1,38 @@
+package notifications
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestBrokerRoutesByRecipient(t *testing.T) {
+	broker := NewBroker(1)
+	alice, bob := uuid.New(), uuid.New()
+	aliceSub := broker.Subscribe(alice)
+	bobSub := broker.Subscribe(bob)
+
+	broker.Publish(Notification{ID: 1, UserID: alice, Kind: KindFollow})
+	if got := <-aliceSub.Notifications(); got.ID != 1 {
+		t.Fatalf("Unexpected notification %v", got)
+	}
+	select {
+	case got := <-bobSub.Notifications():
+		t.Fatalf("Bob received %v", got)
+	default:
+	}
+
+	// A second notification overflows Bob's buffer and closes his channel.
+	broker.Publish(Notification{ID: 2, UserID: bob})
+	broker.Publish(Notification{ID: 3, UserID: bob})
+	<-bobSub.Notifications()
+	if _, ok := <-bobSub.Notifications(); ok {
+		t.Fatal("Expected the slow subscription to be closed")
+	}
+
+	aliceSub.Cancel()
+	aliceSub.Cancel()
+	if _, ok := <-aliceSub.Notifications(); ok {
+		t.Fatal("Expected the cancelled subscription to be closed")
+	}
+}
@@ -0,0 +1,80 @@
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+)
+
+const (
+	KindFollow   = "follow"
+	KindMention  = "mention"
+	KindReaction = "reaction"
+	KindReply    = "reply"
+)
+
+// Notification is the JSON representation sent to clients. ID is the cursor
+// to resume from after a reconnect.
+type Notification struct {
+	ID        int64      `json:"id"`
+	UserID    uuid.UUID  `json:"-"`
+	Kind      string     `json:"kind"`
+	ActorID   uuid.UUID  `json:"actor_id"`
+	ChirpID   *uuid.UUID `json:"chirp_id,omitempty"`
+	Emoji     string     `json:"emoji,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func Map(dbNotification database.Notification) Notification {
+	notification := Notification{
+		ID:        dbNotification.ID,
+		UserID:    dbNotification.UserID,
+		Kind:      dbNotification.Kind,
+		ActorID:   dbNotification.ActorID,
+		Emoji:     dbNotification.Emoji.String,
+		CreatedAt: dbNotification.CreatedAt,
+	}
+	if dbNotification.ChirpID.Valid {
+		notification.ChirpID = &dbNotification.ChirpID.UUID
+	}
+	return notification
+}
+
+// Notifier stores notifications and pushes them to connected recipients.
+type Notifier struct {
+	db     *database.Queries
+	broker *Broker
+	logger *log.Logger
+}
+
+func NewNotifier(db *database.Queries, broker *Broker, logger *log.Logger) *Notifier {
+	return &Notifier{db, broker, logger}
+}
+
+// Notify tells recipient that actor did something of the given kind,
+// optionally about a chirp. Nobody is notified about their own actions.
+//
+// Notifications are a side effect of requests that already succeeded, so
+// failures are logged instead of returned.
+func (n *Notifier) Notify(ctx context.Context, kind string, recipient, actor uuid.UUID, chirpID uuid.NullUUID, emoji string) {
+	if recipient == actor {
+		return
+	}
+
+	created, err := n.db.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:  recipient,
+		Kind:    kind,
+		ActorID: actor,
+		ChirpID: chirpID,
+		Emoji:   sql.NullString{String: emoji, Valid: emoji != ""},
+	})
+	if err != nil {
+		n.logger.Printf("Could not create %s notification: %v\n", kind, err)
+		return
+	}
+	n.broker.Publish(Map(created))
+}
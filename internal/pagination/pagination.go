@@ -0,0 +1,135 @@
+package pagination
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 100
+)
+
+// Cursor points at the last item of a page. Listings are ordered by
+// (created_at, id), so the pair identifies a position in the keyset.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+type Params struct {
+	Limit     int32
+	Cursor    *Cursor
+	Ascending bool
+}
+
+// Encode returns the opaque string representation handed out to clients.
+func (c Cursor) Encode() string {
+	raw := fmt.Sprintf("%d|%s", c.CreatedAt.UnixMicro(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, errors.New("invalid cursor")
+	}
+	micros, id, ok := strings.Cut(string(data), "|")
+	if !ok {
+		return Cursor{}, errors.New("invalid cursor")
+	}
+	ts, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return Cursor{}, errors.New("invalid cursor")
+	}
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return Cursor{}, errors.New("invalid cursor")
+	}
+	return Cursor{CreatedAt: time.UnixMicro(ts).UTC(), ID: parsedID}, nil
+}
+
+// ParseParams reads the limit, cursor and sort query parameters. defaultAsc
+// is the sort order used when none is given.
+func ParseParams(query url.Values, defaultAsc bool) (Params, error) {
+	params := Params{Limit: DefaultLimit, Ascending: defaultAsc}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return Params{}, errors.New("limit must be a positive integer")
+		}
+		params.Limit = int32(min(n, MaxLimit))
+	}
+
+	switch query.Get("sort") {
+	case "":
+	case "asc":
+		params.Ascending = true
+	case "desc":
+		params.Ascending = false
+	default:
+		return Params{}, errors.New("sort must be asc or desc")
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		c, err := DecodeCursor(cursor)
+		if err != nil {
+			return Params{}, err
+		}
+		params.Cursor = &c
+	}
+
+	return params, nil
+}
+
+// CursorArgs returns the cursor as the nullable query arguments used by the
+// keyset queries.
+func (p Params) CursorArgs() (sql.NullTime, uuid.NullUUID) {
+	if p.Cursor == nil {
+		return sql.NullTime{}, uuid.NullUUID{}
+	}
+	return sql.NullTime{Time: p.Cursor.CreatedAt, Valid: true}, uuid.NullUUID{UUID: p.Cursor.ID, Valid: true}
+}
+
+// Trim cuts a result fetched with a limit of p.Limit+1 down to the page size
+// and reports whether there are more items after it.
+func Trim[T any](items []T, p Params) ([]T, bool) {
+	if len(items) > int(p.Limit) {
+		return items[:p.Limit], true
+	}
+	return items, false
+}
+
+// NextLink builds the value of a Link header pointing at the page after
+// cursor, keeping every other query parameter of the current request.
+func NextLink(current *url.URL, cursor Cursor) string {
+	return nextLink(current, "cursor", cursor.Encode())
+}
+
+// NextOffsetLink is NextLink for listings that can't be keyset paginated,
+// such as results ordered by a computed score.
+func NextOffsetLink(current *url.URL, offset int) string {
+	return nextLink(current, "offset", strconv.Itoa(offset))
+}
+
+// NextBeforeLink is NextLink for listings keyed by an increasing numeric ID,
+// read newest first.
+func NextBeforeLink(current *url.URL, id int64) string {
+	return nextLink(current, "before", strconv.FormatInt(id, 10))
+}
+
+func nextLink(current *url.URL, param, value string) string {
+	query := current.Query()
+	query.Set(param, value)
+	next := url.URL{Path: current.Path, RawQuery: query.Encode()}
+	return fmt.Sprintf("<%s>; rel=\"next\"", next.String())
+}
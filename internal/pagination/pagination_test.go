@@ -0,0 +1,60 @@
+package pagination
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := Cursor{CreatedAt: time.Now().UTC().Truncate(time.Microsecond), ID: uuid.New()}
+
+	decoded, err := DecodeCursor(cursor.Encode())
+	if err != nil {
+		t.Fatalf("Failed to decode cursor: %v", err)
+	}
+
+	if !decoded.CreatedAt.Equal(cursor.CreatedAt) || decoded.ID != cursor.ID {
+		t.Fatalf("Expected %v, got %v", cursor, decoded)
+	}
+}
+
+func TestDecodeCursorRejectsGarbage(t *testing.T) {
+	for _, s := range []string{"", "not-base64!", "bm8tc2VwYXJhdG9y"} {
+		if _, err := DecodeCursor(s); err == nil {
+			t.Fatalf("Expected error for cursor %q, but got nil", s)
+		}
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		params, err := ParseParams(url.Values{}, true)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if params.Limit != DefaultLimit || !params.Ascending || params.Cursor != nil {
+			t.Fatalf("Unexpected defaults: %+v", params)
+		}
+	})
+
+	t.Run("Limit is capped", func(t *testing.T) {
+		params, err := ParseParams(url.Values{"limit": {"1000"}, "sort": {"desc"}}, true)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if params.Limit != MaxLimit || params.Ascending {
+			t.Fatalf("Unexpected params: %+v", params)
+		}
+	})
+
+	t.Run("Invalid values", func(t *testing.T) {
+		for _, q := range []url.Values{{"limit": {"0"}}, {"limit": {"abc"}}, {"sort": {"sideways"}}, {"cursor": {"???"}}} {
+			if _, err := ParseParams(q, true); err == nil {
+				t.Fatalf("Expected error for %v, but got nil", q)
+			}
+		}
+	})
+}
@@ -0,0 +1,148 @@
+// Package retryqueue sends the deliveries queued in a table in the
+// background, retrying failed sends with an exponential backoff.
+//
+// Workers claim deliveries one at a time. Claiming pushes the next attempt
+// of a delivery back by the lease, which hides it from other workers while
+// it is being sent. Since a worker holds a single delivery at a time, the
+// lease only has to outlast one send, whose length the HTTP client's
+// timeout bounds.
+package retryqueue
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+const maxErrorBodySize = 512
+
+// Store is the table a Queue sends deliveries of type D from.
+type Store[D any] interface {
+	// ClaimNext returns the most overdue delivery after pushing its next
+	// attempt back by lease, or sql.ErrNoRows when none is due.
+	ClaimNext(ctx context.Context, lease time.Duration) (D, error)
+	// Attempts is how many times delivery was sent before.
+	Attempts(delivery D) int32
+	// Send sends delivery and returns the response status, or 0 when no
+	// response was received.
+	Send(ctx context.Context, delivery D) (int, error)
+
+	MarkDelivered(ctx context.Context, delivery D, status int) error
+	Retry(ctx context.Context, delivery D, status int, sendErr error, next time.Time) error
+	Fail(ctx context.Context, delivery D, status int, sendErr error) error
+}
+
+// Policy sets how a Queue retries.
+type Policy struct {
+	// MaxAttempts is how many times a delivery is sent before it is given
+	// up on.
+	MaxAttempts int32
+	// FirstRetryDelay is the wait after the first failure. It doubles with
+	// every failure after that, up to MaxRetryDelay.
+	FirstRetryDelay time.Duration
+	MaxRetryDelay   time.Duration
+	// Lease has to be longer than a send takes.
+	Lease time.Duration
+}
+
+// Queue sends the deliveries of a Store.
+type Queue[D any] struct {
+	name   string
+	logger *log.Logger
+	store  Store[D]
+	policy Policy
+}
+
+// New returns a queue sending the deliveries of store. name describes them
+// in logs.
+func New[D any](name string, logger *log.Logger, store Store[D], policy Policy) *Queue[D] {
+	return &Queue[D]{name, logger, store, policy}
+}
+
+// RunWorker sends due deliveries every interval until ctx is cancelled.
+func (q *Queue[D]) RunWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := q.DeliverDue(ctx); err != nil && ctx.Err() == nil {
+			q.logger.Printf("%s delivery run failed: %v\n", q.name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue sends every delivery that is due, one at a time.
+func (q *Queue[D]) DeliverDue(ctx context.Context) error {
+	for {
+		delivery, err := q.store.ClaimNext(ctx, q.policy.Lease)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		status, sendErr := q.store.Send(ctx, delivery)
+		if err := q.recordAttempt(ctx, delivery, status, sendErr); err != nil {
+			return err
+		}
+	}
+}
+
+func (q *Queue[D]) recordAttempt(ctx context.Context, delivery D, status int, sendErr error) error {
+	if sendErr == nil {
+		return q.store.MarkDelivered(ctx, delivery, status)
+	}
+
+	attempts := q.store.Attempts(delivery) + 1
+	var permanent permanentError
+	if errors.As(sendErr, &permanent) || attempts >= q.policy.MaxAttempts {
+		return q.store.Fail(ctx, delivery, status, sendErr)
+	}
+	return q.store.Retry(ctx, delivery, status, sendErr, time.Now().Add(q.policy.RetryDelay(attempts)))
+}
+
+// RetryDelay is the wait before the next attempt after attempts failed ones.
+func (p Policy) RetryDelay(attempts int32) time.Duration {
+	if attempts < 1 {
+		return p.FirstRetryDelay
+	}
+	delay := p.FirstRetryDelay << min(attempts-1, 20)
+	return min(delay, p.MaxRetryDelay)
+}
+
+// permanentError marks a send that retrying can't fix.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err as a failure retrying can't fix, so the delivery is
+// given up on at once.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// ResponseError describes a response that isn't a success by its status and
+// the start of its body.
+func ResponseError(resp *http.Response) error {
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(detail))
+}
@@ -0,0 +1,159 @@
+package retryqueue
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+// delivery is a row of memoryStore.
+type delivery struct {
+	id        int
+	attempts  int32
+	next      time.Time
+	delivered bool
+	failed    bool
+}
+
+// memoryStore keeps deliveries in memory and answers sends with the errors
+// queued in results, succeeding once they run out.
+type memoryStore struct {
+	deliveries []*delivery
+	results    []error
+	claims     int
+}
+
+func (s *memoryStore) ClaimNext(_ context.Context, lease time.Duration) (*delivery, error) {
+	for _, d := range s.deliveries {
+		if !d.delivered && !d.failed && !d.next.After(time.Now()) {
+			d.next = time.Now().Add(lease)
+			s.claims++
+			return d, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (s *memoryStore) Attempts(d *delivery) int32 {
+	return d.attempts
+}
+
+func (s *memoryStore) Send(context.Context, *delivery) (int, error) {
+	if len(s.results) == 0 {
+		return 200, nil
+	}
+	err := s.results[0]
+	s.results = s.results[1:]
+	return 500, err
+}
+
+func (s *memoryStore) MarkDelivered(_ context.Context, d *delivery, _ int) error {
+	d.attempts++
+	d.delivered = true
+	return nil
+}
+
+func (s *memoryStore) Retry(_ context.Context, d *delivery, _ int, _ error, next time.Time) error {
+	d.attempts++
+	d.next = next
+	return nil
+}
+
+func (s *memoryStore) Fail(_ context.Context, d *delivery, _ int, _ error) error {
+	d.attempts++
+	d.failed = true
+	return nil
+}
+
+var testPolicy = Policy{
+	MaxAttempts:     3,
+	FirstRetryDelay: 10 * time.Second,
+	MaxRetryDelay:   time.Minute,
+	Lease:           time.Minute,
+}
+
+func newTestQueue(store *memoryStore) *Queue[*delivery] {
+	return New("Test", log.New(io.Discard, "", 0), store, testPolicy)
+}
+
+func TestDeliverDue(t *testing.T) {
+	sendErr := errors.New("503 Service Unavailable")
+
+	t.Run("Sends every due delivery", func(t *testing.T) {
+		store := &memoryStore{deliveries: []*delivery{
+			{id: 1},
+			{id: 2},
+			{id: 3, next: time.Now().Add(time.Hour)},
+		}}
+		if err := newTestQueue(store).DeliverDue(context.Background()); err != nil {
+			t.Fatalf("Delivery run failed: %v", err)
+		}
+		if !store.deliveries[0].delivered || !store.deliveries[1].delivered {
+			t.Fatalf("Expected the due deliveries to be sent")
+		}
+		if store.deliveries[2].delivered || store.claims != 2 {
+			t.Fatalf("Expected the delivery that isn't due to be left alone")
+		}
+	})
+
+	t.Run("Failure is retried later", func(t *testing.T) {
+		store := &memoryStore{deliveries: []*delivery{{id: 1}}, results: []error{sendErr}}
+		queue := newTestQueue(store)
+		if err := queue.DeliverDue(context.Background()); err != nil {
+			t.Fatalf("Delivery run failed: %v", err)
+		}
+		d := store.deliveries[0]
+		if d.delivered || d.failed || d.attempts != 1 {
+			t.Fatalf("Expected the delivery to be rescheduled, got %+v", d)
+		}
+		if wait := time.Until(d.next); wait < 9*time.Second || wait > testPolicy.FirstRetryDelay {
+			t.Fatalf("Expected a retry in %v, got %v", testPolicy.FirstRetryDelay, wait)
+		}
+
+		d.next = time.Now()
+		if err := queue.DeliverDue(context.Background()); err != nil {
+			t.Fatalf("Delivery run failed: %v", err)
+		}
+		if !d.delivered || d.attempts != 2 {
+			t.Fatalf("Expected the retry to be delivered, got %+v", d)
+		}
+	})
+
+	t.Run("Given up after MaxAttempts", func(t *testing.T) {
+		store := &memoryStore{deliveries: []*delivery{{id: 1, attempts: testPolicy.MaxAttempts - 1}}, results: []error{sendErr}}
+		if err := newTestQueue(store).DeliverDue(context.Background()); err != nil {
+			t.Fatalf("Delivery run failed: %v", err)
+		}
+		if d := store.deliveries[0]; !d.failed || d.attempts != testPolicy.MaxAttempts {
+			t.Fatalf("Expected the delivery to be given up, got %+v", d)
+		}
+	})
+
+	t.Run("Permanent failure is not retried", func(t *testing.T) {
+		store := &memoryStore{deliveries: []*delivery{{id: 1}}, results: []error{Permanent(sendErr)}}
+		if err := newTestQueue(store).DeliverDue(context.Background()); err != nil {
+			t.Fatalf("Delivery run failed: %v", err)
+		}
+		if d := store.deliveries[0]; !d.failed || d.attempts != 1 {
+			t.Fatalf("Expected the delivery to be given up, got %+v", d)
+		}
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempts, expected := range map[int32]time.Duration{
+		1:  10 * time.Second,
+		2:  20 * time.Second,
+		3:  40 * time.Second,
+		4:  time.Minute,
+		40: time.Minute,
+	} {
+		if delay := testPolicy.RetryDelay(attempts); delay != expected {
+			t.Errorf("Expected a delay of %v after %d attempts, got %v", expected, attempts, delay)
+		}
+	}
+}
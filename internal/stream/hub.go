@@ -0,0 +1,156 @@
+package stream
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TypeChirp  = "chirp"
+	TypeDelete = "delete"
+)
+
+// Event is a message broadcast to every subscriber. Type names the event in
+// the stream, Key is the chirp it is about, and AuthorID and Tags let
+// subscribers filter events without decoding Data.
+type Event struct {
+	ID       uint64
+	Type     string
+	Key      uuid.UUID
+	Data     []byte
+	AuthorID uuid.UUID
+	Tags     []string
+}
+
+// Hub fans events out to subscribers in the same process. It keeps the most
+// recent events in a ring buffer so clients that reconnect can catch up on
+// what they missed.
+//
+// Publishing never blocks: every subscriber has its own buffer, and a
+// subscriber whose buffer is full is dropped rather than slowing everyone
+// down. Its channel is closed and Dropped reports true.
+type Hub struct {
+	mu          sync.Mutex
+	nextID      uint64
+	history     []Event
+	historyNext int
+	historyFull bool
+	subscribers map[*Subscription]struct{}
+	bufferSize  int
+}
+
+type Subscription struct {
+	hub     *Hub
+	events  chan Event
+	dropped bool
+}
+
+// NewHub creates a hub remembering historySize events and buffering up to
+// bufferSize events per subscriber.
+//
+// Event IDs start at the current Unix time in microseconds, so IDs handed
+// out after a restart are still larger than those seen before it.
+func NewHub(historySize, bufferSize int) *Hub {
+	return &Hub{
+		nextID:      uint64(time.Now().UnixMicro()),
+		history:     make([]Event, historySize),
+		subscribers: map[*Subscription]struct{}{},
+		bufferSize:  bufferSize,
+	}
+}
+
+// Publish assigns the event an ID and delivers it to every subscriber.
+func (h *Hub) Publish(event Event) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.nextID++
+	event.ID = h.nextID
+
+	if len(h.history) > 0 {
+		h.history[h.historyNext] = event
+		h.historyNext = (h.historyNext + 1) % len(h.history)
+		h.historyFull = h.historyFull || h.historyNext == 0
+	}
+
+	for sub := range h.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			sub.dropped = true
+			h.remove(sub)
+		}
+	}
+	return event.ID
+}
+
+// Forget removes the remembered events about key, so that clients resuming
+// later don't get them replayed. Events already delivered are not affected.
+func (h *Hub) Forget(key uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i := range h.history {
+		if h.history[i].ID != 0 && h.history[i].Key == key {
+			// A zero ID is never after the ID a client resumes from.
+			h.history[i] = Event{}
+		}
+	}
+}
+
+// Subscribe registers a new subscriber. When lastEventID is not zero, the
+// remembered events published after it are returned so the caller can send
+// them before reading from the subscription.
+func (h *Hub) Subscribe(lastEventID uint64) (*Subscription, []Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub := &Subscription{hub: h, events: make(chan Event, h.bufferSize)}
+	h.subscribers[sub] = struct{}{}
+
+	var missed []Event
+	if lastEventID != 0 {
+		for _, event := range h.historyInOrder() {
+			if event.ID > lastEventID {
+				missed = append(missed, event)
+			}
+		}
+	}
+	return sub, missed
+}
+
+// Events returns the channel events are delivered on. It is closed when the
+// subscription is cancelled or dropped.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped reports whether the hub dropped the subscriber for falling behind.
+func (s *Subscription) Dropped() bool {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.dropped
+}
+
+// Cancel unregisters the subscription. It is safe to call more than once.
+func (s *Subscription) Cancel() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+func (h *Hub) remove(sub *Subscription) {
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+func (h *Hub) historyInOrder() []Event {
+	if !h.historyFull {
+		return h.history[:h.historyNext]
+	}
+	return append(append([]Event{}, h.history[h.historyNext:]...), h.history[:h.historyNext]...)
+}
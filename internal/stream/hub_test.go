@@ -0,0 +1,69 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestHubDeliversAndResumes(t *testing.T) {
+	hub := NewHub(3, 10)
+
+	var ids []uint64
+	for _, data := range []string{"a", "b", "c", "d"} {
+		ids = append(ids, hub.Publish(Event{Data: []byte(data)}))
+	}
+
+	// Only the last three events are remembered.
+	_, missed := hub.Subscribe(ids[0] - 1)
+	if len(missed) != 3 || string(missed[0].Data) != "b" || string(missed[2].Data) != "d" {
+		t.Fatalf("Unexpected replay %v", missed)
+	}
+
+	sub, missed := hub.Subscribe(ids[2])
+	if len(missed) != 1 || missed[0].ID != ids[3] {
+		t.Fatalf("Expected to resume after %d, got %v", ids[2], missed)
+	}
+
+	hub.Publish(Event{Data: []byte("e")})
+	if event := <-sub.Events(); string(event.Data) != "e" {
+		t.Fatalf("Unexpected event %q", event.Data)
+	}
+
+	sub.Cancel()
+	sub.Cancel()
+	if _, ok := <-sub.Events(); ok {
+		t.Fatal("Expected the channel to be closed")
+	}
+}
+
+func TestHubDropsSlowSubscribers(t *testing.T) {
+	hub := NewHub(0, 1)
+	slow, _ := hub.Subscribe(0)
+
+	hub.Publish(Event{Data: []byte("a")})
+	hub.Publish(Event{Data: []byte("b")})
+
+	if !slow.Dropped() {
+		t.Fatal("Expected the slow subscriber to be dropped")
+	}
+	<-slow.Events()
+	if _, ok := <-slow.Events(); ok {
+		t.Fatal("Expected the channel to be closed")
+	}
+}
+
+func TestHubForget(t *testing.T) {
+	hub := NewHub(10, 10)
+	deleted, kept := uuid.New(), uuid.New()
+
+	first := hub.Publish(Event{Key: deleted, Data: []byte("a")})
+	hub.Publish(Event{Key: kept, Data: []byte("b")})
+	hub.Forget(deleted)
+	hub.Publish(Event{Type: TypeDelete, Key: deleted, Data: []byte("c")})
+
+	_, missed := hub.Subscribe(first - 1)
+	if len(missed) != 2 || string(missed[0].Data) != "b" || string(missed[1].Data) != "c" {
+		t.Fatalf("Expected the forgotten event to be skipped, got %v", missed)
+	}
+}
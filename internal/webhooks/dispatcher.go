@@ -0,0 +1,174 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+	"github.com/sheltonFr/bootdev/chirspy/internal/retryqueue"
+)
+
+const (
+	EventChirpCreated = "chirp.created"
+	EventChirpUpdated = "chirp.updated"
+	EventChirpDeleted = "chirp.deleted"
+	EventUserCreated  = "user.created"
+	EventUserUpdated  = "user.updated"
+	EventUserUpgraded = "user.upgraded"
+)
+
+// Events lists every event a webhook can subscribe to.
+var Events = []string{
+	EventChirpCreated,
+	EventChirpUpdated,
+	EventChirpDeleted,
+	EventUserCreated,
+	EventUserUpdated,
+	EventUserUpgraded,
+}
+
+func IsValidEvent(event string) bool {
+	return slices.Contains(Events, event)
+}
+
+// MaxAttempts is how many times a payload is sent before its delivery is
+// marked as failed.
+const MaxAttempts = 8
+
+const sendTimeout = 10 * time.Second
+
+var deliveryPolicy = retryqueue.Policy{
+	MaxAttempts:     MaxAttempts,
+	FirstRetryDelay: 10 * time.Second,
+	MaxRetryDelay:   6 * time.Hour,
+	// A send is bounded by sendTimeout.
+	Lease: time.Minute,
+}
+
+// Payload is the JSON body posted to webhooks. ID identifies the event, so
+// receivers can drop a payload they get twice after a retry.
+type Payload struct {
+	ID        uuid.UUID `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// Dispatcher queues events for the webhooks subscribed to them and delivers
+// them in the background.
+type Dispatcher struct {
+	db     *database.Queries
+	logger *log.Logger
+	client *http.Client
+	queue  *retryqueue.Queue[database.ClaimNextWebhookDeliveryRow]
+}
+
+func NewDispatcher(db *database.Queries, logger *log.Logger) *Dispatcher {
+	d := &Dispatcher{db: db, logger: logger, client: &http.Client{Timeout: sendTimeout}}
+	d.queue = retryqueue.New("Webhook", logger, deliveryStore{d}, deliveryPolicy)
+	return d
+}
+
+// Enqueue queues an event with q, which is bound to the transaction making
+// the change, so that the event is only sent if the change commits.
+func (d *Dispatcher) Enqueue(ctx context.Context, q *database.Queries, event string, data any) error {
+	payload, err := json.Marshal(Payload{
+		ID:        uuid.New(),
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+	return q.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		Event:   event,
+		Payload: string(payload),
+	})
+}
+
+// RunWorker delivers due payloads every interval until ctx is cancelled.
+func (d *Dispatcher) RunWorker(ctx context.Context, interval time.Duration) {
+	d.queue.RunWorker(ctx, interval)
+}
+
+// DeliverDue sends every payload that is due. Each failure pushes the next
+// attempt back by an exponential backoff until MaxAttempts is reached.
+func (d *Dispatcher) DeliverDue(ctx context.Context) error {
+	return d.queue.DeliverDue(ctx)
+}
+
+// deliveryStore is the retryqueue.Store of webhook_deliveries.
+type deliveryStore struct {
+	d *Dispatcher
+}
+
+func (s deliveryStore) ClaimNext(ctx context.Context, lease time.Duration) (database.ClaimNextWebhookDeliveryRow, error) {
+	return s.d.db.ClaimNextWebhookDelivery(ctx, lease.Seconds())
+}
+
+func (s deliveryStore) Attempts(delivery database.ClaimNextWebhookDeliveryRow) int32 {
+	return delivery.WebhookDelivery.Attempts
+}
+
+func (s deliveryStore) MarkDelivered(ctx context.Context, delivery database.ClaimNextWebhookDeliveryRow, status int) error {
+	return s.d.db.MarkWebhookDeliveryDelivered(ctx, database.MarkWebhookDeliveryDeliveredParams{
+		ID:             delivery.WebhookDelivery.ID,
+		ResponseStatus: responseStatus(status),
+	})
+}
+
+func (s deliveryStore) Retry(ctx context.Context, delivery database.ClaimNextWebhookDeliveryRow, status int, sendErr error, next time.Time) error {
+	return s.d.db.RetryWebhookDelivery(ctx, database.RetryWebhookDeliveryParams{
+		ID:             delivery.WebhookDelivery.ID,
+		NextAttemptAt:  next,
+		ResponseStatus: responseStatus(status),
+		LastError:      sendErr.Error(),
+	})
+}
+
+func (s deliveryStore) Fail(ctx context.Context, delivery database.ClaimNextWebhookDeliveryRow, status int, sendErr error) error {
+	return s.d.db.FailWebhookDelivery(ctx, database.FailWebhookDeliveryParams{
+		ID:             delivery.WebhookDelivery.ID,
+		ResponseStatus: responseStatus(status),
+		LastError:      sendErr.Error(),
+	})
+}
+
+// Send posts one payload. Any status outside 2xx is an error.
+func (s deliveryStore) Send(ctx context.Context, delivery database.ClaimNextWebhookDeliveryRow) (int, error) {
+	body := []byte(delivery.WebhookDelivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.WebhookDelivery.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.WebhookDelivery.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, body))
+
+	resp, err := s.d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, nil
+	}
+	return resp.StatusCode, retryqueue.ResponseError(resp)
+}
+
+// responseStatus is NULL when no response was received.
+func responseStatus(status int) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(status), Valid: status != 0}
+}
@@ -0,0 +1,105 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sheltonFr/bootdev/chirspy/internal/database"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"event":"chirp.created"}`)
+	signature := Sign("secret", 1700000000, body)
+
+	t.Run("Valid signature", func(t *testing.T) {
+		if !Verify("secret", "1700000000", body, signature) {
+			t.Fatalf("Expected the signature to verify")
+		}
+	})
+
+	t.Run("Wrong secret", func(t *testing.T) {
+		if Verify("other", "1700000000", body, signature) {
+			t.Fatalf("Expected a signature made with another secret to be rejected")
+		}
+	})
+
+	t.Run("Other timestamp", func(t *testing.T) {
+		if Verify("secret", "1700000001", body, signature) {
+			t.Fatalf("Expected a signature of another timestamp to be rejected")
+		}
+	})
+
+	t.Run("Tampered body", func(t *testing.T) {
+		if Verify("secret", "1700000000", []byte(`{"event":"user.created"}`), signature) {
+			t.Fatalf("Expected a signature of another body to be rejected")
+		}
+	})
+
+	t.Run("Missing prefix", func(t *testing.T) {
+		if Verify("secret", "1700000000", body, signature[len(signaturePrefix):]) {
+			t.Fatalf("Expected a signature without its prefix to be rejected")
+		}
+	})
+
+	t.Run("Bad timestamp", func(t *testing.T) {
+		if Verify("secret", "soon", body, signature) {
+			t.Fatalf("Expected a signature with an invalid timestamp to be rejected")
+		}
+	})
+}
+
+// TestSendToLocalReceiver delivers a payload to a local HTTP receiver that
+// checks it the way a subscriber would.
+func TestSendToLocalReceiver(t *testing.T) {
+	const secret = "whsec_test"
+	payload := `{"id":"e5c1","event":"chirp.created","data":{}}`
+
+	send := func(t *testing.T, status int) (int, error) {
+		t.Helper()
+		received := make(chan bool, 1)
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			received <- string(body) == payload &&
+				r.Header.Get(HeaderEvent) == EventChirpCreated &&
+				r.Header.Get(HeaderDelivery) == "42" &&
+				Verify(secret, r.Header.Get(HeaderTimestamp), body, r.Header.Get(HeaderSignature))
+			w.WriteHeader(status)
+		}))
+		defer receiver.Close()
+
+		dispatcher := NewDispatcher(nil, log.New(io.Discard, "", 0))
+		code, err := deliveryStore{dispatcher}.Send(context.Background(), database.ClaimNextWebhookDeliveryRow{
+			WebhookDelivery: database.WebhookDelivery{ID: 42, Event: EventChirpCreated, Payload: payload},
+			Url:             receiver.URL,
+			Secret:          secret,
+		})
+		if !<-received {
+			t.Fatalf("Receiver got an unexpected or badly signed request")
+		}
+		return code, err
+	}
+
+	t.Run("Accepted", func(t *testing.T) {
+		code, err := send(t, http.StatusNoContent)
+		if err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		if code != http.StatusNoContent {
+			t.Fatalf("Expected status 204, got %d", code)
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		code, err := send(t, http.StatusInternalServerError)
+		if err == nil {
+			t.Fatalf("Expected error for a 500 response, but got nil")
+		}
+		if code != http.StatusInternalServerError {
+			t.Fatalf("Expected status 500, got %d", code)
+		}
+	})
+}
@@ -1,25 +1,49 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/sheltonFr/bootdev/chirspy/internal/activitypub"
+	"github.com/sheltonFr/bootdev/chirspy/internal/auth"
 	"github.com/sheltonFr/bootdev/chirspy/internal/database"
 	"github.com/sheltonFr/bootdev/chirspy/internal/handlers"
+	"github.com/sheltonFr/bootdev/chirspy/internal/media"
+	"github.com/sheltonFr/bootdev/chirspy/internal/notifications"
+	"github.com/sheltonFr/bootdev/chirspy/internal/stream"
+	"github.com/sheltonFr/bootdev/chirspy/internal/webhooks"
 )
 
 const filePathRoot = "."
 const port = "8080"
+const platformDev = "dev"
+const trendingRefreshInterval = 5 * time.Minute
+const deliveryInterval = 15 * time.Second
+const webhookInterval = 5 * time.Second
+const defaultMediaDir = "uploads"
+const mediaURLPrefix = "/media"
+
+// The chirp stream remembers enough events for clients to resume after a
+// short disconnect, and drops clients that fall this far behind.
+const streamHistorySize = 1000
+const streamBufferSize = 64
+const notificationBufferSize = 32
 
 type apiConfig struct {
 	fileServerHits atomic.Int32
 	db             *database.Queries
-	jwtSecret      string
+	conn           *sql.DB
+	jwtKeys        *auth.KeySet
+	platform       string
+	logger         *log.Logger
 }
 
 func main() {
@@ -34,37 +58,153 @@ func main() {
 
 	logger := log.New(os.Stdout, "chirpy-api: ", log.Flags())
 
-	jwtSecret := os.Getenv("JWT_SECRET")
+	jwtKeys, err := auth.LoadKeySet(os.Getenv("JWT_SECRET"), splitList(os.Getenv("JWT_SIGNING_KEYS")))
+	if err != nil {
+		log.Fatal("Could not load JWT signing keys: ", err)
+	}
+	polkaKey := os.Getenv("POLKA_KEY")
+	baseURL := os.Getenv("BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:" + port
+	}
+
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = defaultMediaDir
+	}
+	mediaStorage, err := media.NewLocalStorage(mediaDir, mediaURLPrefix)
+	if err != nil {
+		log.Fatal("Could not set up media storage: ", err)
+	}
 
 	apiCfg := apiConfig{
 		fileServerHits: atomic.Int32{},
 		db:             dbQueries,
-		jwtSecret:      jwtSecret,
+		conn:           db,
+		jwtKeys:        jwtKeys,
+		platform:       os.Getenv("PLATFORM"),
+		logger:         logger,
+	}
+
+	chirpHub := stream.NewHub(streamHistorySize, streamBufferSize)
+	notificationBroker := notifications.NewBroker(notificationBufferSize)
+	notifier := notifications.NewNotifier(dbQueries, notificationBroker, logger)
+	dispatcher := webhooks.NewDispatcher(dbQueries, logger)
+	federation, err := activitypub.NewFederation(dbQueries, logger, baseURL)
+	if err != nil {
+		log.Fatal("Could not set up federation: ", err)
+	}
+	if os.Getenv("FEDERATION_ALLOW_PRIVATE") == "true" {
+		federation.AllowPrivateNetworks()
 	}
 
-	userHandler := handlers.NewUserHandler(dbQueries, logger)
-	chirpyHandler := handlers.NewChirpyHandler(dbQueries, logger, apiCfg.jwtSecret)
-	authHandler := handlers.NewAuthHandler(dbQueries, logger, apiCfg.jwtSecret)
+	userHandler := handlers.NewUserHandler(dbQueries, db, logger, apiCfg.jwtKeys, dispatcher)
+	chirpyHandler := handlers.NewChirpyHandler(dbQueries, db, logger, apiCfg.jwtKeys, mediaStorage, chirpHub, notifier, federation, dispatcher)
+	authHandler := handlers.NewAuthHandler(dbQueries, db, logger, apiCfg.jwtKeys)
+	reactionHandler := handlers.NewReactionHandler(dbQueries, logger, apiCfg.jwtKeys, notifier)
+	followHandler := handlers.NewFollowHandler(dbQueries, logger, apiCfg.jwtKeys, notifier)
+	sessionHandler := handlers.NewSessionHandler(dbQueries, logger, apiCfg.jwtKeys)
+	polkaHandler := handlers.NewPolkaHandler(dbQueries, db, logger, polkaKey, dispatcher)
+	tagHandler := handlers.NewTagHandler(dbQueries, logger)
+	mediaHandler := handlers.NewMediaHandler(dbQueries, logger, apiCfg.jwtKeys, mediaStorage)
+	streamHandler := handlers.NewStreamHandler(chirpHub, logger)
+	feedHandler := handlers.NewFeedHandler(dbQueries, logger, baseURL)
+	notificationHandler := handlers.NewNotificationHandler(dbQueries, logger, apiCfg.jwtKeys, notificationBroker)
+	activityPubHandler := handlers.NewActivityPubHandler(dbQueries, logger, apiCfg.jwtKeys, federation)
+	webhookHandler := handlers.NewWebhookHandler(dbQueries, logger)
+	conversationHandler := handlers.NewConversationHandler(dbQueries, db, logger, apiCfg.jwtKeys)
+	blockHandler := handlers.NewBlockHandler(dbQueries, db, logger, apiCfg.jwtKeys)
+
+	go tagHandler.RunTrendingRefresher(context.Background(), trendingRefreshInterval)
+	go federation.RunDeliveryWorker(context.Background(), deliveryInterval)
+	go dispatcher.RunWorker(context.Background(), webhookInterval)
 
 	mux := http.NewServeMux()
 
 	fsHandler := apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filePathRoot))))
 	mux.Handle("/app/", fsHandler)
+	mux.Handle(mediaURLPrefix+"/", middlewareMediaFiles(http.StripPrefix(mediaURLPrefix, http.FileServer(http.Dir(mediaDir)))))
 
 	mux.HandleFunc("GET /api/healthz", handlerReadiness)
-	mux.HandleFunc("GET /admin/metrics", apiCfg.handleMetrics)
-	mux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
+	mux.HandleFunc("GET /.well-known/jwks.json", apiCfg.handlerJWKS)
+	mux.HandleFunc("GET /admin/metrics", apiCfg.middlewareAdminOnly(apiCfg.handleMetrics))
+	mux.HandleFunc("POST /admin/reset", apiCfg.middlewareAdminOnly(apiCfg.handlerReset))
+	mux.HandleFunc("POST /admin/webhooks", apiCfg.middlewareAdminOnly(webhookHandler.CreateWebhook))
+	mux.HandleFunc("GET /admin/webhooks", apiCfg.middlewareAdminOnly(webhookHandler.ListWebhooks))
+	mux.HandleFunc("DELETE /admin/webhooks/{webhookID}", apiCfg.middlewareAdminOnly(webhookHandler.DeleteWebhook))
+	mux.HandleFunc("GET /admin/webhooks/{webhookID}/deliveries", apiCfg.middlewareAdminOnly(webhookHandler.ListDeliveries))
 	mux.HandleFunc("POST /api/users", userHandler.CreateUser)
+	mux.HandleFunc("PUT /api/users", userHandler.UpdateUser)
+	mux.HandleFunc("GET /api/users/search", userHandler.SearchUsers)
+	mux.HandleFunc("GET /api/users/{userID}", userHandler.GetProfile)
+	mux.HandleFunc("PUT /api/users/me/profile", userHandler.UpdateProfile)
+	mux.HandleFunc("POST /api/users/{userID}/follow", followHandler.Follow)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", followHandler.Unfollow)
+	mux.HandleFunc("GET /api/users/{userID}/followers", followHandler.ListFollowers)
+	mux.HandleFunc("GET /api/users/{userID}/following", followHandler.ListFollowing)
+	mux.HandleFunc("GET /api/users/me/mentions", chirpyHandler.GetMentions)
+	mux.HandleFunc("POST /api/users/{userID}/block", blockHandler.Block)
+	mux.HandleFunc("DELETE /api/users/{userID}/block", blockHandler.Unblock)
+	mux.HandleFunc("POST /api/users/{userID}/mute", blockHandler.Mute)
+	mux.HandleFunc("DELETE /api/users/{userID}/mute", blockHandler.Unmute)
+	mux.HandleFunc("GET /api/users/me/blocks", blockHandler.ListBlocks)
+	mux.HandleFunc("GET /api/users/me/mutes", blockHandler.ListMutes)
 
 	mux.HandleFunc("POST /api/chirps", chirpyHandler.CreateChirpy)
 	mux.HandleFunc("GET /api/chirps", chirpyHandler.GetAllChirps)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", chirpyHandler.GetChirpyById)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", chirpyHandler.GetThread)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}/reactions/{emoji}", reactionHandler.PutReaction)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/reactions/{emoji}", reactionHandler.DeleteReaction)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", chirpyHandler.UpdateChirpy)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", chirpyHandler.DeleteChirpy)
+	mux.HandleFunc("GET /api/timeline", chirpyHandler.GetTimeline)
+	mux.HandleFunc("GET /api/search/chirps", chirpyHandler.SearchChirps)
+	mux.HandleFunc("GET /api/stream/chirps", streamHandler.StreamChirps)
+
+	//Direct messages
+	mux.HandleFunc("POST /api/conversations", conversationHandler.CreateConversation)
+	mux.HandleFunc("GET /api/conversations", conversationHandler.ListConversations)
+	mux.HandleFunc("GET /api/conversations/{conversationID}/messages", conversationHandler.ListMessages)
+	mux.HandleFunc("POST /api/conversations/{conversationID}/messages", conversationHandler.CreateMessage)
+
+	//Feeds
+	mux.HandleFunc("GET /feeds/{file}", feedHandler.GlobalFeed)
+	mux.HandleFunc("GET /feeds/users/{file}", feedHandler.UserFeed)
+	mux.HandleFunc("GET /feeds/tags/{file}", feedHandler.TagFeed)
+
+	//ActivityPub
+	mux.HandleFunc("GET /.well-known/webfinger", activityPubHandler.WebFinger)
+	mux.HandleFunc("GET /ap/users/{userID}", activityPubHandler.GetActor)
+	mux.HandleFunc("GET /ap/users/{userID}/outbox", activityPubHandler.GetOutbox)
+	mux.HandleFunc("GET /ap/users/{userID}/followers", activityPubHandler.GetFollowers)
+	mux.HandleFunc("POST /ap/users/{userID}/inbox", activityPubHandler.Inbox)
+	mux.HandleFunc("GET /ap/chirps/{chirpID}", activityPubHandler.GetNote)
+	mux.HandleFunc("POST /api/users/me/remote-follows", activityPubHandler.FollowRemote)
+
+	//Media
+	mux.HandleFunc("POST /api/media", mediaHandler.UploadMedia)
+
+	//Tags
+	mux.HandleFunc("GET /api/tags/trending", tagHandler.GetTrending)
+	mux.HandleFunc("GET /api/tags/{tag}/chirps", chirpyHandler.GetChirpsByTag)
+
+	//Webhooks
+	mux.HandleFunc("POST /api/polka/webhooks", polkaHandler.WebhookHandler)
 
 	//Auth
 	mux.HandleFunc("POST /api/login", authHandler.LoginHandler)
 	mux.HandleFunc("POST /api/refresh", authHandler.RefreshTokenHandler)
 	mux.HandleFunc("POST /api/revoke", authHandler.RevokeRefreshTokenHandler)
 
+	//Notifications
+	mux.HandleFunc("GET /api/notifications/ws", notificationHandler.Connect)
+
+	//Sessions
+	mux.HandleFunc("GET /api/sessions", sessionHandler.ListSessions)
+	mux.HandleFunc("DELETE /api/sessions/{id}", sessionHandler.RevokeSession)
+	mux.HandleFunc("POST /api/sessions/revoke-all", sessionHandler.RevokeAllSessions)
+
 	srv := &http.Server{
 		Addr:    ":" + port,
 		Handler: mux,
@@ -72,3 +212,15 @@ func main() {
 	logger.Printf("Serving files from %s on port: %s\n", filePathRoot, port)
 	logger.Fatal(srv.ListenAndServe())
 }
+
+// splitList splits a comma separated environment variable, dropping empty
+// entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/sheltonFr/bootdev/chirspy/internal/auth"
+	"github.com/sheltonFr/bootdev/chirspy/internal/utils"
+)
+
+const roleAdmin = "admin"
+
+// middlewareAdminOnly only lets requests through when they carry a valid
+// access token belonging to a user with the admin role.
+func (cfg *apiConfig) middlewareAdminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		userID, err := cfg.jwtKeys.ValidateJWT(token)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		user, err := cfg.db.GetUserByID(r.Context(), userID)
+		if err != nil || user.Role != roleAdmin {
+			utils.RespondWithError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		next(w, r)
+	}
+}
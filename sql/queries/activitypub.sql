@@ -0,0 +1,105 @@
+-- name: CreateActorKey :exec
+INSERT INTO actor_keys (user_id, private_key_pem, public_key_pem, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: GetActorKey :one
+SELECT * FROM actor_keys
+WHERE user_id = $1;
+
+-- name: UpsertRemoteFollower :exec
+INSERT INTO remote_followers (user_id, actor_uri, inbox_url, shared_inbox_url, created_at)
+VALUES ($1, $2, $3, $4, NOW())
+ON CONFLICT (user_id, actor_uri) DO UPDATE
+SET inbox_url = EXCLUDED.inbox_url, shared_inbox_url = EXCLUDED.shared_inbox_url;
+
+-- name: DeleteRemoteFollower :exec
+DELETE FROM remote_followers
+WHERE user_id = $1 AND actor_uri = $2;
+
+-- name: CountRemoteFollowers :one
+SELECT COUNT(*) FROM remote_followers
+WHERE user_id = $1;
+
+-- name: UpsertRemoteFollow :exec
+-- Following an account again replaces the pending Follow, which has to be
+-- accepted anew.
+INSERT INTO remote_follows (user_id, actor_uri, follow_id, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (user_id, actor_uri) DO UPDATE
+SET follow_id = EXCLUDED.follow_id, accepted_at = NULL;
+
+-- name: AcceptRemoteFollow :execrows
+UPDATE remote_follows
+SET accepted_at = NOW()
+WHERE user_id = $1 AND actor_uri = $2 AND follow_id = $3;
+
+-- name: IsFollowingRemoteActor :one
+SELECT EXISTS (
+    SELECT 1 FROM remote_follows
+    WHERE user_id = $1 AND actor_uri = $2 AND accepted_at IS NOT NULL
+);
+
+-- name: UpsertRemoteNote :exec
+-- A Note delivered again replaces the stored one, unless it comes from
+-- another actor.
+INSERT INTO remote_notes (id, actor_uri, content, url, in_reply_to, published_at, received_at)
+VALUES ($1, $2, $3, $4, $5, $6, NOW())
+ON CONFLICT (id) DO UPDATE
+SET content = EXCLUDED.content, url = EXCLUDED.url, in_reply_to = EXCLUDED.in_reply_to
+WHERE remote_notes.actor_uri = EXCLUDED.actor_uri;
+
+-- name: DeleteRemoteNote :execrows
+DELETE FROM remote_notes
+WHERE id = $1 AND actor_uri = $2;
+
+-- name: ListOutboxChirps :many
+-- The chirps published as Notes: rechirps have no content of their own.
+SELECT * FROM chirps
+WHERE user_id = $1 AND deleted_at IS NULL AND kind <> 'rechirp'
+ORDER BY created_at DESC, id DESC
+LIMIT $2;
+
+-- name: CountOutboxChirps :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1 AND deleted_at IS NULL AND kind <> 'rechirp';
+
+-- name: EnqueueDelivery :exec
+INSERT INTO ap_deliveries (sender_id, inbox_url, payload)
+VALUES ($1, $2, $3);
+
+-- name: EnqueueDeliveryToFollowers :exec
+-- Queues the payload once per inbox of the sender's remote followers,
+-- preferring shared inboxes so a server gets it once.
+INSERT INTO ap_deliveries (sender_id, inbox_url, payload)
+SELECT DISTINCT remote_followers.user_id, COALESCE(NULLIF(shared_inbox_url, ''), inbox_url), sqlc.arg('payload')::text
+FROM remote_followers
+WHERE remote_followers.user_id = sqlc.arg('sender_id');
+
+-- name: ClaimNextDelivery :one
+-- Claims the most overdue delivery for the lease, see internal/retryqueue.
+UPDATE ap_deliveries
+SET next_attempt_at = NOW() + make_interval(secs => sqlc.arg('lease_seconds')::float8)
+WHERE id = (
+    SELECT id FROM ap_deliveries
+    WHERE delivered_at IS NULL AND failed_at IS NULL AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkDeliveryDelivered :exec
+UPDATE ap_deliveries
+SET delivered_at = NOW(), attempts = attempts + 1, last_error = ''
+WHERE id = $1;
+
+-- name: RetryDelivery :exec
+UPDATE ap_deliveries
+SET attempts = attempts + 1, next_attempt_at = $2, last_error = $3
+WHERE id = $1;
+
+-- name: FailDelivery :exec
+UPDATE ap_deliveries
+SET attempts = attempts + 1, failed_at = NOW(), last_error = $2
+WHERE id = $1;
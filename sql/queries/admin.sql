@@ -0,0 +1,7 @@
+-- name: ResetDatabase :exec
+TRUNCATE TABLE refresh_tokens, chirps, tags, webhooks, conversations, remote_notes CASCADE;
+
+-- name: DeleteNonAdminUsers :exec
+-- Admin accounts survive a reset, since nothing else can grant the role.
+DELETE FROM users
+WHERE role <> 'admin';
@@ -1,12 +1,151 @@
 -- name: CreateChirpy :one
-INSERT INTO chirps (id, body, user_id, created_at, updated_at)
-VALUES (gen_random_uuid(), $1, $2, NOW(), NOW())
+INSERT INTO chirps (id, body, user_id, in_reply_to, kind, referenced_chirp_id, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, NOW(), NOW())
 RETURNING *;
 
 -- name: GetChirps :many
 SELECT * FROM chirps
+WHERE deleted_at IS NULL
 ORDER BY created_at ASC;
 
 -- name: GetChirpyByID :one
 SELECT * FROM chirps
 WHERE id = $1 LIMIT 1;
+
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg('ids')::uuid[]);
+
+-- name: UpdateChirpy :one
+UPDATE chirps
+SET body = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteChirpy :execrows
+-- Deleted chirps are kept as tombstones so that their replies keep their
+-- place in the thread. Affects no rows when the chirp was already deleted.
+UPDATE chirps
+SET body = '', deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: IncrementReplyCount :exec
+UPDATE chirps
+SET reply_count = reply_count + 1
+WHERE id = $1;
+
+-- name: DecrementReplyCount :exec
+UPDATE chirps
+SET reply_count = GREATEST(reply_count - 1, 0)
+WHERE id = $1;
+
+-- name: ListChirpsAsc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+AND NOT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = sqlc.narg('viewer_id')::uuid AND blocked_id = user_id)
+AND NOT EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = sqlc.narg('viewer_id')::uuid AND muted_id = user_id)
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('limit');
+
+-- name: ListChirpsDesc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+AND NOT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = sqlc.narg('viewer_id')::uuid AND blocked_id = user_id)
+AND NOT EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = sqlc.narg('viewer_id')::uuid AND muted_id = user_id)
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListTimelineAsc :many
+SELECT chirps.* FROM chirps
+JOIN follows ON follows.followee_id = chirps.user_id
+WHERE follows.follower_id = sqlc.arg('follower_id')
+AND chirps.deleted_at IS NULL
+AND NOT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = sqlc.arg('follower_id') AND blocked_id = chirps.user_id)
+AND NOT EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = sqlc.arg('follower_id') AND muted_id = chirps.user_id)
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT sqlc.arg('limit');
+
+-- name: ListTimelineDesc :many
+SELECT chirps.* FROM chirps
+JOIN follows ON follows.followee_id = chirps.user_id
+WHERE follows.follower_id = sqlc.arg('follower_id')
+AND chirps.deleted_at IS NULL
+AND NOT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = sqlc.arg('follower_id') AND blocked_id = chirps.user_id)
+AND NOT EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = sqlc.arg('follower_id') AND muted_id = chirps.user_id)
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetChirpAncestors :many
+-- Walks up the in_reply_to chain of a chirp and returns its ancestors, root
+-- first.
+WITH RECURSIVE ancestors (id, in_reply_to, depth) AS (
+    SELECT c.id, c.in_reply_to, 0 FROM chirps c
+    WHERE c.id = $1
+    UNION ALL
+    SELECT c.id, c.in_reply_to, a.depth + 1 FROM chirps c
+    JOIN ancestors a ON c.id = a.in_reply_to
+)
+SELECT chirps.* FROM chirps
+JOIN ancestors ON ancestors.id = chirps.id
+WHERE ancestors.depth > 0
+ORDER BY ancestors.depth DESC;
+
+-- name: ListReplyTree :many
+-- Returns the replies below a chirp, at any depth, in depth-first order:
+-- every reply is followed by its own replies, siblings being ordered by
+-- (created_at, id). Pages continue after the reply given as the cursor.
+-- Replies by users the viewer blocked or muted are left out along with the
+-- replies below them.
+WITH RECURSIVE tree (id, depth, path) AS (
+    SELECT c.id, 1, ARRAY[to_char(c.created_at, 'YYYYMMDDHH24MISSUS') || c.id::text]
+    FROM chirps c
+    WHERE c.in_reply_to = sqlc.arg('chirp_id')
+    AND NOT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = sqlc.narg('viewer_id')::uuid AND blocked_id = c.user_id)
+    AND NOT EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = sqlc.narg('viewer_id')::uuid AND muted_id = c.user_id)
+    UNION ALL
+    SELECT c.id, t.depth + 1, t.path || (to_char(c.created_at, 'YYYYMMDDHH24MISSUS') || c.id::text)
+    FROM chirps c
+    JOIN tree t ON c.in_reply_to = t.id
+    WHERE NOT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = sqlc.narg('viewer_id')::uuid AND blocked_id = c.user_id)
+    AND NOT EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = sqlc.narg('viewer_id')::uuid AND muted_id = c.user_id)
+)
+SELECT sqlc.embed(chirps), tree.depth FROM tree
+JOIN chirps ON chirps.id = tree.id
+WHERE (
+    sqlc.narg('cursor_id')::uuid IS NULL
+    OR tree.path > (SELECT seen.path FROM tree seen WHERE seen.id = sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY tree.path
+LIMIT sqlc.arg('limit');
+
+-- name: GetLastChirpChange :one
+-- Returns when a chirp of the author, or with the tag, last changed. Deleting
+-- a chirp sets its updated_at, so deletions count as changes.
+SELECT MAX(updated_at)::timestamp AS last_changed FROM chirps
+WHERE (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+AND (
+    sqlc.narg('tag')::text IS NULL
+    OR EXISTS (
+        SELECT 1 FROM chirp_tags
+        JOIN tags ON tags.id = chirp_tags.tag_id
+        WHERE chirp_tags.chirp_id = chirps.id AND tags.name = sqlc.narg('tag')::text
+    )
+);
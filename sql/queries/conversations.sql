@@ -0,0 +1,98 @@
+-- name: CreateConversation :one
+-- Returns no rows when the pair already has a conversation.
+INSERT INTO conversations (id, direct_key, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, NOW(), NOW())
+ON CONFLICT (direct_key) DO NOTHING
+RETURNING *;
+
+-- name: GetConversationIDByDirectKey :one
+SELECT id FROM conversations
+WHERE direct_key = $1;
+
+-- name: AddConversationParticipant :exec
+INSERT INTO conversation_participants (conversation_id, user_id)
+VALUES ($1, $2);
+
+-- name: GetConversationForUser :one
+-- Loads a conversation as seen by one of its participants, with the other
+-- participant and the number of messages the user hasn't read.
+SELECT sqlc.embed(conversations), sqlc.embed(users),
+    (
+        SELECT COUNT(*) FROM messages
+        WHERE messages.conversation_id = conversations.id
+        AND messages.sender_id <> me.user_id
+        AND (me.last_read_at IS NULL OR messages.created_at > me.last_read_at)
+    ) AS unread_count
+FROM conversation_participants me
+JOIN conversations ON conversations.id = me.conversation_id
+JOIN conversation_participants other ON other.conversation_id = me.conversation_id AND other.user_id <> me.user_id
+JOIN users ON users.id = other.user_id
+WHERE me.conversation_id = sqlc.arg('conversation_id') AND me.user_id = sqlc.arg('user_id');
+
+-- name: ListConversations :many
+-- Lists the user's conversations, most recently active first. The cursor is
+-- the updated_at and ID of the last conversation seen.
+SELECT sqlc.embed(conversations), sqlc.embed(users),
+    (
+        SELECT COUNT(*) FROM messages
+        WHERE messages.conversation_id = conversations.id
+        AND messages.sender_id <> me.user_id
+        AND (me.last_read_at IS NULL OR messages.created_at > me.last_read_at)
+    ) AS unread_count
+FROM conversation_participants me
+JOIN conversations ON conversations.id = me.conversation_id
+JOIN conversation_participants other ON other.conversation_id = me.conversation_id AND other.user_id <> me.user_id
+JOIN users ON users.id = other.user_id
+WHERE me.user_id = sqlc.arg('user_id')
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (conversations.updated_at, conversations.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY conversations.updated_at DESC, conversations.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: IsConversationParticipant :one
+SELECT EXISTS (
+    SELECT 1 FROM conversation_participants
+    WHERE conversation_id = $1 AND user_id = $2
+);
+
+-- name: GetOtherParticipantID :one
+SELECT user_id FROM conversation_participants
+WHERE conversation_id = $1 AND user_id <> $2
+LIMIT 1;
+
+-- name: MarkConversationRead :exec
+-- Moves the read marker up to read_at, never back.
+UPDATE conversation_participants
+SET last_read_at = GREATEST(last_read_at, sqlc.arg('read_at')::timestamp)
+WHERE conversation_id = sqlc.arg('conversation_id') AND user_id = sqlc.arg('user_id');
+
+-- name: CreateMessage :one
+INSERT INTO messages (id, conversation_id, sender_id, body, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, NOW())
+RETURNING *;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = NOW()
+WHERE id = $1;
+
+-- name: ListMessages :many
+-- Lists the messages of a conversation, newest first.
+SELECT * FROM messages
+WHERE conversation_id = sqlc.arg('conversation_id')
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: IsBlockedBetween :one
+-- Reports whether either user blocked the other.
+SELECT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (blocker_id = sqlc.arg('user_a') AND blocked_id = sqlc.arg('user_b'))
+    OR (blocker_id = sqlc.arg('user_b') AND blocked_id = sqlc.arg('user_a'))
+);
@@ -0,0 +1,30 @@
+-- name: CreateMedia :one
+INSERT INTO media (id, user_id, content_type, width, height, size_bytes, storage_key, thumbnail_key, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+RETURNING *;
+
+-- name: GetUnattachedMedia :many
+-- Returns the uploads among ids that belong to the user and aren't attached
+-- to a chirp yet.
+SELECT * FROM media
+WHERE id = ANY(sqlc.arg('ids')::uuid[])
+AND user_id = sqlc.arg('user_id')
+AND NOT EXISTS (SELECT 1 FROM chirp_media WHERE chirp_media.media_id = media.id);
+
+-- name: AttachMedia :exec
+INSERT INTO chirp_media (chirp_id, media_id, position, alt_text)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetChirpMedia :many
+SELECT chirp_media.chirp_id, chirp_media.alt_text, sqlc.embed(media)
+FROM chirp_media
+JOIN media ON media.id = chirp_media.media_id
+WHERE chirp_media.chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY chirp_media.chirp_id, chirp_media.position;
+
+-- name: DeleteChirpMedia :many
+-- Deletes the uploads attached to a chirp and returns them so their files
+-- can be removed from storage.
+DELETE FROM media
+WHERE id IN (SELECT media_id FROM chirp_media WHERE chirp_id = $1)
+RETURNING *;
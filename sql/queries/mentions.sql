@@ -0,0 +1,43 @@
+-- name: CreateMentions :many
+-- Links the chirp to the users with the given lowercased usernames and
+-- returns the IDs of the users who weren't mentioned by it before. Unknown
+-- usernames and users who blocked the author are ignored.
+INSERT INTO mentions (chirp_id, user_id, created_at)
+SELECT sqlc.arg('chirp_id')::uuid, users.id, NOW() FROM users
+WHERE LOWER(users.username) = ANY(sqlc.arg('usernames')::text[])
+AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    JOIN chirps ON chirps.user_id = user_blocks.blocked_id
+    WHERE chirps.id = sqlc.arg('chirp_id')::uuid AND user_blocks.blocker_id = users.id
+)
+ON CONFLICT DO NOTHING
+RETURNING user_id;
+
+-- name: DeleteMentionsNotIn :exec
+-- Drops the mentions a chirp lost when it was edited.
+DELETE FROM mentions
+WHERE chirp_id = sqlc.arg('chirp_id')
+AND user_id NOT IN (
+    SELECT id FROM users
+    WHERE LOWER(username) = ANY(sqlc.arg('usernames')::text[])
+);
+
+-- name: GetChirpMentions :many
+SELECT mentions.chirp_id, users.id AS user_id, users.username
+FROM mentions
+JOIN users ON users.id = mentions.user_id
+WHERE mentions.chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
+
+-- name: ListMentions :many
+SELECT chirps.* FROM chirps
+JOIN mentions ON mentions.chirp_id = chirps.id
+WHERE mentions.user_id = sqlc.arg('user_id')
+AND chirps.deleted_at IS NULL
+AND NOT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = sqlc.arg('user_id') AND blocked_id = chirps.user_id)
+AND NOT EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = sqlc.arg('user_id') AND muted_id = chirps.user_id)
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('limit');
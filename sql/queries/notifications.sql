@@ -0,0 +1,26 @@
+-- name: CreateNotification :one
+INSERT INTO notifications (user_id, kind, actor_id, chirp_id, emoji, created_at)
+VALUES ($1, $2, $3, $4, $5, NOW())
+RETURNING *;
+
+-- name: ListNotificationsAfter :many
+-- Returns the user's notifications newer than the cursor, oldest first.
+SELECT * FROM notifications
+WHERE user_id = sqlc.arg('user_id') AND id > sqlc.arg('after_id')
+ORDER BY id
+LIMIT sqlc.arg('limit');
+
+-- name: ListNotificationsNear :many
+-- Returns the user's notifications up to the cursor that were created at
+-- most overlap_seconds before it, oldest first. Concurrent inserts can commit
+-- out of id order, so these may have been missed by a client resuming from
+-- the cursor.
+SELECT * FROM notifications
+WHERE user_id = sqlc.arg('user_id') AND id <= sqlc.arg('cursor_id')
+AND created_at >= (
+    SELECT cursor_row.created_at - make_interval(secs => sqlc.arg('overlap_seconds')::float8)
+    FROM notifications cursor_row
+    WHERE cursor_row.id = sqlc.arg('cursor_id')
+)
+ORDER BY id
+LIMIT sqlc.arg('limit');
@@ -0,0 +1,21 @@
+-- name: CreateReaction :execrows
+INSERT INTO reactions (chirp_id, user_id, emoji, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteReaction :execrows
+DELETE FROM reactions
+WHERE chirp_id = $1 AND user_id = $2 AND emoji = $3;
+
+-- name: GetReactionSummaries :many
+-- Aggregates the reactions of several chirps at once, flagging the emojis
+-- the viewer used.
+SELECT
+    chirp_id,
+    emoji,
+    COUNT(*) AS count,
+    COALESCE(BOOL_OR(user_id = sqlc.narg('viewer_id')::uuid), FALSE)::bool AS reacted
+FROM reactions
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+GROUP BY chirp_id, emoji
+ORDER BY chirp_id, count DESC, emoji;
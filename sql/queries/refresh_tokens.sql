@@ -1,6 +1,8 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, user_id, expires_at, created_at, updated_at)
-VALUES ($1, $2, $3, NOW(), NOW())
+INSERT INTO refresh_tokens (
+    token, user_id, expires_at, family_id, user_agent, ip_address, last_used_at, created_at, updated_at
+)
+VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW(), NOW())
 RETURNING *;
 
 -- name: GetRefreshToken :one
@@ -11,3 +13,30 @@ WHERE token = $1 LIMIT 1;
 UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
 WHERE token = $1;
+
+-- name: RevokeAllRefreshTokensForUser :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: RotateRefreshToken :execrows
+-- Revokes a token in favour of its successor. Affects no rows when the token
+-- was already revoked, which callers treat as reuse.
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW(), replaced_by = sqlc.arg('replaced_by')
+WHERE token = sqlc.arg('token') AND revoked_at IS NULL;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL;
+
+-- name: ListSessionsForUser :many
+SELECT family_id, user_agent, ip_address, last_used_at, expires_at FROM refresh_tokens
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+ORDER BY last_used_at DESC;
+
+-- name: RevokeSessionForUser :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = $1 AND user_id = $2 AND revoked_at IS NULL;
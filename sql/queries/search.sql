@@ -0,0 +1,39 @@
+-- name: SearchChirpsByRank :many
+-- Matches chirps against a web search style query ("phrases", -excluded, or)
+-- and orders them by relevance. Highlighted terms in the snippet are wrapped
+-- in U+0002 and U+0003.
+SELECT
+    sqlc.embed(chirps),
+    ts_rank_cd(chirps.search_vector, query)::float8 AS rank,
+    ts_headline('english', chirps.body, query, 'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', MaxFragments=2, MaxWords=20, MinWords=5')::text AS snippet
+FROM chirps, websearch_to_tsquery('english', sqlc.arg('query')) AS query
+WHERE chirps.search_vector @@ query
+AND chirps.deleted_at IS NULL
+AND (sqlc.narg('author_id')::uuid IS NULL OR chirps.user_id = sqlc.narg('author_id')::uuid)
+AND (sqlc.narg('since')::timestamp IS NULL OR chirps.created_at >= sqlc.narg('since')::timestamp)
+AND (sqlc.narg('until')::timestamp IS NULL OR chirps.created_at < sqlc.narg('until')::timestamp)
+AND NOT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = sqlc.narg('viewer_id')::uuid AND blocked_id = chirps.user_id)
+AND NOT EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = sqlc.narg('viewer_id')::uuid AND muted_id = chirps.user_id)
+ORDER BY rank DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: SearchChirpsByRecency :many
+-- Same as SearchChirpsByRank, newest first with keyset pagination.
+SELECT
+    sqlc.embed(chirps),
+    ts_rank_cd(chirps.search_vector, query)::float8 AS rank,
+    ts_headline('english', chirps.body, query, 'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', MaxFragments=2, MaxWords=20, MinWords=5')::text AS snippet
+FROM chirps, websearch_to_tsquery('english', sqlc.arg('query')) AS query
+WHERE chirps.search_vector @@ query
+AND chirps.deleted_at IS NULL
+AND (sqlc.narg('author_id')::uuid IS NULL OR chirps.user_id = sqlc.narg('author_id')::uuid)
+AND (sqlc.narg('since')::timestamp IS NULL OR chirps.created_at >= sqlc.narg('since')::timestamp)
+AND (sqlc.narg('until')::timestamp IS NULL OR chirps.created_at < sqlc.narg('until')::timestamp)
+AND NOT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = sqlc.narg('viewer_id')::uuid AND blocked_id = chirps.user_id)
+AND NOT EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = sqlc.narg('viewer_id')::uuid AND muted_id = chirps.user_id)
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('limit');
@@ -0,0 +1,46 @@
+-- name: TagChirp :exec
+-- Creates the missing tags and links all of them to the chirp.
+WITH upserted AS (
+    INSERT INTO tags (name)
+    SELECT unnest(sqlc.arg('names')::text[])
+    ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+    RETURNING id
+)
+INSERT INTO chirp_tags (chirp_id, tag_id)
+SELECT sqlc.arg('chirp_id')::uuid, id FROM upserted
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteChirpTags :exec
+DELETE FROM chirp_tags
+WHERE chirp_id = $1;
+
+-- name: ListChirpsByTag :many
+SELECT chirps.* FROM chirps
+JOIN chirp_tags ON chirp_tags.chirp_id = chirps.id
+JOIN tags ON tags.id = chirp_tags.tag_id
+WHERE tags.name = sqlc.arg('tag')
+AND chirps.deleted_at IS NULL
+AND NOT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = sqlc.narg('viewer_id')::uuid AND blocked_id = chirps.user_id)
+AND NOT EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = sqlc.narg('viewer_id')::uuid AND muted_id = chirps.user_id)
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetTrendingTags :many
+-- Scores the tags used inside the window, each chirp counting for less as it
+-- ages: its weight halves every half_life_seconds.
+SELECT
+    tags.name,
+    SUM(EXP(-LN(2) * EXTRACT(EPOCH FROM NOW() - chirps.created_at) / sqlc.arg('half_life_seconds')::float8))::float8 AS score,
+    COUNT(*) AS chirp_count
+FROM chirp_tags
+JOIN tags ON tags.id = chirp_tags.tag_id
+JOIN chirps ON chirps.id = chirp_tags.chirp_id
+WHERE chirps.deleted_at IS NULL
+AND chirps.created_at > NOW() - make_interval(secs => sqlc.arg('window_seconds')::float8)
+GROUP BY tags.name
+ORDER BY score DESC, tags.name
+LIMIT sqlc.arg('limit');
@@ -1,12 +1,58 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
-VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, username)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2, $3)
 RETURNING *;
 
 -- name: GetUserByID :one
 SELECT * FROM users
 WHERE id = $1 LIMIT 1;
 
+-- name: GetUsersByIDs :many
+SELECT * FROM users
+WHERE id = ANY(sqlc.arg('ids')::uuid[]);
+
 -- name: GetUserByEmail :one
 SELECT * FROM users
 WHERE email = $1 LIMIT 1;
+
+-- name: UpdateUser :one
+UPDATE users
+SET email = $2, hashed_password = $3, username = $4, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetUserProfile :one
+SELECT
+    sqlc.embed(users),
+    (SELECT COUNT(*) FROM chirps WHERE chirps.user_id = users.id AND chirps.deleted_at IS NULL) AS chirp_count,
+    (SELECT COUNT(*) FROM follows WHERE follows.followee_id = users.id) AS follower_count,
+    (SELECT COUNT(*) FROM follows WHERE follows.follower_id = users.id) AS following_count
+FROM users
+WHERE users.id = $1;
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET display_name = $2, bio = $3, avatar_url = $4, location = $5, website = $6, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: UpgradeUserToChirpyRed :execrows
+UPDATE users
+SET is_chirpy_red = TRUE, updated_at = NOW()
+WHERE id = $1;
+
+-- name: SearchUsers :many
+-- Finds users whose username starts with the pattern's prefix. An exact
+-- match comes first, then the shortest usernames.
+SELECT * FROM users
+WHERE LOWER(username) LIKE sqlc.arg('pattern') ESCAPE '\'
+ORDER BY
+    COALESCE(LOWER(username) = sqlc.arg('query'), FALSE) DESC,
+    LENGTH(username),
+    LOWER(username),
+    id
+LIMIT sqlc.arg('limit');
+
+-- name: GetUserByUsername :one
+SELECT * FROM users
+WHERE LOWER(username) = LOWER($1) LIMIT 1;
@@ -0,0 +1,60 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, url, events, secret, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, NOW(), NOW())
+RETURNING *;
+
+-- name: ListWebhooks :many
+SELECT * FROM webhooks
+ORDER BY created_at;
+
+-- name: GetWebhook :one
+SELECT * FROM webhooks
+WHERE id = $1;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE id = $1;
+
+-- name: EnqueueWebhookDeliveries :exec
+-- Queues the payload for every webhook subscribed to the event.
+INSERT INTO webhook_deliveries (webhook_id, event, payload)
+SELECT id, sqlc.arg('event')::text, sqlc.arg('payload')::text
+FROM webhooks
+WHERE sqlc.arg('event')::text = ANY(events);
+
+-- name: ClaimNextWebhookDelivery :one
+-- Claims the most overdue delivery for the lease, see internal/retryqueue.
+UPDATE webhook_deliveries
+SET next_attempt_at = NOW() + make_interval(secs => sqlc.arg('lease_seconds')::float8)
+FROM webhooks
+WHERE webhooks.id = webhook_deliveries.webhook_id
+AND webhook_deliveries.id = (
+    SELECT webhook_deliveries.id FROM webhook_deliveries
+    WHERE delivered_at IS NULL AND failed_at IS NULL AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING sqlc.embed(webhook_deliveries), webhooks.url, webhooks.secret;
+
+-- name: MarkWebhookDeliveryDelivered :exec
+UPDATE webhook_deliveries
+SET delivered_at = NOW(), attempts = attempts + 1, response_status = $2, last_error = ''
+WHERE id = $1;
+
+-- name: RetryWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET attempts = attempts + 1, next_attempt_at = $2, response_status = $3, last_error = $4
+WHERE id = $1;
+
+-- name: FailWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET attempts = attempts + 1, failed_at = NOW(), response_status = $2, last_error = $3
+WHERE id = $1;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE webhook_id = sqlc.arg('webhook_id')
+AND (sqlc.narg('before_id')::bigint IS NULL OR id < sqlc.narg('before_id')::bigint)
+ORDER BY id DESC
+LIMIT sqlc.arg('limit');
@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE refresh_tokens
+ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+ADD COLUMN ip_address TEXT NOT NULL DEFAULT '',
+ADD COLUMN last_used_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- Tokens are now stored as their SHA-256 hex digest.
+UPDATE refresh_tokens
+SET token = encode(sha256(token::bytea), 'hex'),
+    replaced_by = encode(sha256(replaced_by::bytea), 'hex');
+
+-- +goose Down
+-- Hashed tokens cannot be recovered; existing sessions stay unusable.
+ALTER TABLE refresh_tokens
+DROP COLUMN IF EXISTS last_used_at,
+DROP COLUMN IF EXISTS ip_address,
+DROP COLUMN IF EXISTS user_agent;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- Deleted chirps become tombstones with an empty body, so bodies can no
+-- longer be unique.
+ALTER TABLE chirps
+DROP CONSTRAINT IF EXISTS chirps_body_key;
+
+ALTER TABLE chirps
+ADD COLUMN in_reply_to UUID REFERENCES chirps(id) ON DELETE SET NULL,
+ADD COLUMN reply_count INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX chirps_in_reply_to_created_at_id_idx ON chirps (in_reply_to, created_at, id);
+
+-- +goose Down
+DROP INDEX IF EXISTS chirps_in_reply_to_created_at_id_idx;
+
+DELETE FROM chirps
+WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE chirps
+DROP COLUMN IF EXISTS deleted_at,
+DROP COLUMN IF EXISTS reply_count,
+DROP COLUMN IF EXISTS in_reply_to;
+
+ALTER TABLE chirps
+ADD CONSTRAINT chirps_body_key UNIQUE (body);
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE reactions (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    emoji TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chirp_id, user_id, emoji)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS reactions;
@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN kind TEXT NOT NULL DEFAULT 'chirp'
+CHECK (kind IN ('chirp', 'rechirp', 'quote')),
+ADD COLUMN referenced_chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL;
+
+CREATE INDEX chirps_referenced_chirp_id_idx ON chirps (referenced_chirp_id);
+
+-- A user can only rechirp a chirp once.
+CREATE UNIQUE INDEX chirps_user_id_rechirp_idx ON chirps (user_id, referenced_chirp_id)
+WHERE kind = 'rechirp' AND deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS chirps_user_id_rechirp_idx;
+DROP INDEX IF EXISTS chirps_referenced_chirp_id_idx;
+
+ALTER TABLE chirps
+DROP COLUMN IF EXISTS referenced_chirp_id,
+DROP COLUMN IF EXISTS kind;
@@ -0,0 +1,22 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN username TEXT;
+
+-- Usernames are unique regardless of case; existing users don't have one yet.
+CREATE UNIQUE INDEX users_username_lower_idx ON users (LOWER(username));
+
+CREATE TABLE mentions (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chirp_id, user_id)
+);
+
+CREATE INDEX mentions_user_id_idx ON mentions (user_id, chirp_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS mentions;
+DROP INDEX IF EXISTS users_username_lower_idx;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS username;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- text_pattern_ops lets LIKE 'prefix%' use the index whatever the collation.
+CREATE INDEX users_username_prefix_idx ON users (LOWER(username) text_pattern_ops);
+
+-- +goose Down
+DROP INDEX IF EXISTS users_username_prefix_idx;
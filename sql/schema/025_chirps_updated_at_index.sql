@@ -0,0 +1,6 @@
+-- +goose Up
+-- Lets feeds find when any chirp last changed without scanning the table.
+CREATE INDEX chirps_updated_at_idx ON chirps (updated_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS chirps_updated_at_idx;
@@ -0,0 +1,29 @@
+-- +goose Up
+-- Accounts on other servers that local users follow. The Follow activity is
+-- identified by follow_id; accepted_at is set once the remote server accepts
+-- it.
+CREATE TABLE remote_follows (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_uri TEXT NOT NULL,
+    follow_id TEXT NOT NULL UNIQUE,
+    accepted_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, actor_uri)
+);
+
+-- Notes published by followed remote accounts, as delivered to their inboxes.
+CREATE TABLE remote_notes (
+    id TEXT PRIMARY KEY,
+    actor_uri TEXT NOT NULL,
+    content TEXT NOT NULL,
+    url TEXT NOT NULL DEFAULT '',
+    in_reply_to TEXT NOT NULL DEFAULT '',
+    published_at TIMESTAMP NOT NULL,
+    received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX remote_notes_actor_uri_idx ON remote_notes (actor_uri, published_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS remote_notes;
+DROP TABLE IF EXISTS remote_follows;